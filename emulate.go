@@ -0,0 +1,42 @@
+package main
+
+import (
+	"image"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/pgavlin/lilprinty/internal/emulator"
+)
+
+// emulate decodes the printer command stream stored at inputPath and writes the printed output to outputPath as a
+// PNG. An inputPath of "-" reads the stream from stdin.
+func emulate(inputPath, outputPath string, width int) error {
+	var r io.Reader = os.Stdin
+	if inputPath != "-" {
+		f, err := os.Open(inputPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	img, err := emulator.Decode(r, width)
+	if err != nil {
+		return err
+	}
+	return writePNG(outputPath, img)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pgavlin/lilprinty/internal/markdown"
+	"github.com/pgavlin/lilprinty/internal/printer"
+)
+
+var update = flag.Bool("update", false, "regenerate the golden images checked by TestGolden")
+
+// TestGolden renders each Markdown fixture in testdata/golden and compares the result against the fixture's golden
+// PNG. The golden image for "name.md" is "name.png". If -update is set, the golden images are regenerated instead.
+// When a comparison fails, an image that highlights the differing pixels is written to "name.diff.png". Fixtures are
+// rendered for the 58mm profile using the default style unless they have their own stylesheet, "name.json". Relative
+// paths in fixtures and stylesheets are resolved against the fixture directory.
+func TestGolden(t *testing.T) {
+	dir, err := filepath.Abs(filepath.Join("testdata", "golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(fixtures)
+
+	profile := printer.Profile58mm
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			base := strings.TrimSuffix(fixture, ".md")
+			goldenPath, diffPath := base+".png", base+".diff.png"
+
+			style := defaultStyle(profile.DPI)
+			if _, err := os.Stat(base + ".json"); err == nil {
+				if style, err = loadStylesheet(base+".json", profile.DPI); err != nil {
+					t.Fatalf("loading stylesheet: %v", err)
+				}
+			}
+			style.BaseDir = dir
+
+			actual, err := renderGolden(fixture, profile, style)
+			if err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+
+			if *update {
+				if err = writePNG(goldenPath, actual); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.Remove(diffPath); err != nil && !os.IsNotExist(err) {
+					t.Fatal(err)
+				}
+				t.Logf("updated %v", goldenPath)
+				return
+			}
+
+			expected, err := readPNG(goldenPath)
+			if err != nil {
+				t.Fatalf("reading golden image: %v", err)
+			}
+
+			diff, ok := diffImages(expected, actual)
+			if ok {
+				if err = os.Remove(diffPath); err != nil && !os.IsNotExist(err) {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if err = writePNG(diffPath, diff); err != nil {
+				t.Fatal(err)
+			}
+			t.Errorf("output does not match %v (see %v)", goldenPath, diffPath)
+		})
+	}
+}
+
+// renderGolden renders the Markdown fixture at path into an in-memory device.
+func renderGolden(path string, profile printer.Profile, style markdown.Style) (image.Image, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPreview(profile)
+	if err = markdown.Render(p, contents, style); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// diffImages compares two images pixel by pixel. If the images differ, the result is an image that shows the
+// expected image in light gray with mismatched pixels in red.
+func diffImages(expected, actual image.Image) (image.Image, bool) {
+	eb, ab := expected.Bounds(), actual.Bounds()
+	bounds := eb.Union(ab)
+
+	diff := image.NewRGBA(bounds)
+	same := eb == ab
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := image.Point{x, y}
+
+			var e, a color.Gray
+			if p.In(eb) {
+				e = color.GrayModel.Convert(expected.At(x, y)).(color.Gray)
+			}
+			if p.In(ab) {
+				a = color.GrayModel.Convert(actual.At(x, y)).(color.Gray)
+			}
+
+			if p.In(eb) != p.In(ab) || e != a {
+				diff.Set(x, y, color.RGBA{R: 0xff, A: 0xff})
+				same = false
+			} else {
+				v := 0xc0 + e.Y/4
+				diff.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 0xff})
+			}
+		}
+	}
+	return diff, same
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return png.Decode(f)
+}
@@ -1,10 +1,39 @@
 $(document).ready(function() {
 	var src = $("#source");
 
+	var status = $("#job-status");
+	function showJob(job) {
+		var text = "job " + job.id + ": " + job.state;
+		if (job.error) {
+			text += " (" + job.error + ")";
+		}
+		status.text(text);
+	}
+
+	function pollJob(id) {
+		$.getJSON("/jobs/" + id).done(function(job) {
+			showJob(job);
+			if (job.state === "queued" || job.state === "printing") {
+				window.setTimeout(function() { pollJob(id); }, 500);
+			}
+		}).fail(function() {
+			status.text("job " + id + ": unknown");
+		});
+	}
+
 	$("#print").click(function() {
-		var req = new XMLHttpRequest();
-		req.open("POST", "/print");
-		req.send(src.val());
+		$.ajax({
+			type: "POST",
+			url: "/print",
+			data: src.val(),
+			processData: false,
+			dataType: "json"
+		}).done(function(job) {
+			showJob(job);
+			pollJob(job.id);
+		}).fail(function(xhr) {
+			status.text("print failed: " + xhr.statusText);
+		});
 	});
 
 	var img = $("#preview").get(0);
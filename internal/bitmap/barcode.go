@@ -0,0 +1,26 @@
+package bitmap
+
+// A Barcode describes a one-dimensional barcode that a BarcodeDevice may be able to print natively.
+type Barcode struct {
+	Symbology   string // The barcode's symbology: "code128", "ean13", or "upca".
+	Data        string // The barcode's contents, including any check digit.
+	Modules     int    // The width of the barcode in modules, excluding its quiet zones.
+	ModuleWidth int    // The width of each module in dots.
+	Height      int    // The height of the bars in dots.
+	PrintText   bool   // True if the barcode's contents should be printed beneath it.
+
+	// Raster is the barcode rendered as a bitmap the width of the device. Devices that record their output for later
+	// display may print it in place of the native barcode.
+	Raster *Image
+}
+
+// A BarcodeDevice is a Device that can print some barcodes natively. Native barcodes require far fewer bytes than the
+// equivalent bitmaps, which matters over slow links.
+type BarcodeDevice interface {
+	Device
+
+	// SupportsBarcode returns true if the device can print the given barcode natively.
+	SupportsBarcode(b Barcode) bool
+	// PrintBarcode prints the given barcode centered on the paper.
+	PrintBarcode(b Barcode) error
+}
@@ -0,0 +1,205 @@
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// hriHeight is the height in dots of the human-readable text printed beneath a barcode. The emulator draws the text
+// in a smaller face than the printer's 12x24 font, but reserves the same amount of space for it.
+const hriHeight = 24
+
+// justification decodes an ESC a command, which sets the justification of subsequent barcodes.
+func (e *Emulator) justification(r *bufio.Reader) error {
+	n, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	e.state.justification = n % 48 // The printer accepts both 0-2 and '0'-'2'.
+	return nil
+}
+
+// hriPosition decodes a GS H command, which sets the position of a barcode's human-readable text.
+func (e *Emulator) hriPosition(r *bufio.Reader) error {
+	n, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	e.state.hriPosition = n % 48
+	return nil
+}
+
+// barcodeHeight decodes a GS h command, which sets the height of a barcode's bars.
+func (e *Emulator) barcodeHeight(r *bufio.Reader) error {
+	n, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("barcode height must be at least 1 dot")
+	}
+	e.state.barcodeHeight = int(n)
+	return nil
+}
+
+// moduleWidth decodes a GS w command, which sets the width of a barcode's narrowest bar.
+func (e *Emulator) moduleWidth(r *bufio.Reader) error {
+	n, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if n < 2 || n > 6 {
+		return fmt.Errorf("barcode module width must be in the range [2, 6], not %d", n)
+	}
+	e.state.moduleWidth = int(n)
+	return nil
+}
+
+// barcode decodes a GS k command, which prints a barcode of system m with n bytes of data. Only the length-prefixed
+// forms of UPC-A (m = 65), EAN-13 (m = 67), and Code 128 (m = 73) are supported.
+func (e *Emulator) barcode(r *bufio.Reader) error {
+	var args [2]byte
+	if _, err := io.ReadFull(r, args[:]); err != nil {
+		return err
+	}
+	data := make([]byte, int(args[1]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+
+	var code barcode.Barcode
+	var text string
+	var err error
+	switch args[0] {
+	case 65:
+		if len(data) != 11 && len(data) != 12 {
+			return fmt.Errorf("UPC-A barcodes must have 11 or 12 digits")
+		}
+		code, err = ean.Encode("0" + string(data))
+		if err == nil {
+			text = code.Content()[1:]
+		}
+	case 67:
+		if len(data) != 12 && len(data) != 13 {
+			return fmt.Errorf("EAN-13 barcodes must have 12 or 13 digits")
+		}
+		code, err = ean.Encode(string(data))
+		if err == nil {
+			text = code.Content()
+		}
+	case 73:
+		text, err = decodeCode128(data)
+		if err == nil {
+			code, err = code128.Encode(text)
+		}
+	default:
+		return fmt.Errorf("unsupported barcode system %d", args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("invalid barcode: %v", err)
+	}
+
+	width := code.Bounds().Dx() * e.state.moduleWidth
+	if width > e.width {
+		return fmt.Errorf("barcode of %d dots exceeds the printer width of %d dots", width, e.width)
+	}
+
+	height := e.state.barcodeHeight
+	if e.state.hriPosition == 2 {
+		height += hriHeight
+	}
+	img := bitmap.New(image.Rect(0, 0, e.width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	left := 0
+	switch e.state.justification {
+	case 1:
+		left = (e.width - width) / 2
+	case 2:
+		left = e.width - width
+	}
+	for x := 0; x < width; x++ {
+		if code.At(x/e.state.moduleWidth, 0) == color.Black {
+			draw.Draw(img, image.Rect(left+x, 0, left+x+1, e.state.barcodeHeight), image.NewUniform(color.Black),
+				image.Point{}, draw.Src)
+		}
+	}
+
+	if e.state.hriPosition == 2 {
+		face := basicfont.Face7x13
+		drawer := font.Drawer{Dst: img, Src: image.NewUniform(color.Black), Face: face}
+		textWidth := drawer.MeasureString(text)
+		drawer.Dot = fixed.Point26_6{
+			X: fixed.I(left+width/2) - textWidth/2,
+			Y: fixed.I(e.state.barcodeHeight + (hriHeight+face.Ascent-face.Descent)/2),
+		}
+		drawer.DrawString(text)
+	}
+
+	e.appendImage(img)
+	return nil
+}
+
+// decodeCode128 decodes the data of a Code 128 GS k command. The data must begin with a code set selector. Only code
+// sets A and B, which encode ASCII text, and code set C, which encodes pairs of digits, are supported.
+func decodeCode128(data []byte) (string, error) {
+	if len(data) < 2 || data[0] != '{' {
+		return "", fmt.Errorf("Code 128 data must begin with a code set selector")
+	}
+
+	var text []byte
+	codeSet := byte(0)
+	for i := 0; i < len(data); i++ {
+		if data[i] != '{' {
+			if codeSet != 'C' {
+				text = append(text, data[i])
+				continue
+			}
+			if data[i] > 99 {
+				return "", fmt.Errorf("invalid Code 128 code set C value %d", data[i])
+			}
+			text = append(text, '0'+data[i]/10, '0'+data[i]%10)
+			continue
+		}
+
+		i++
+		if i == len(data) {
+			return "", fmt.Errorf("incomplete Code 128 escape")
+		}
+		switch data[i] {
+		case '{':
+			text = append(text, '{')
+		case 'A', 'B', 'C':
+			codeSet = data[i]
+		default:
+			return "", fmt.Errorf("unsupported Code 128 escape '{%c'", data[i])
+		}
+	}
+	return string(text), nil
+}
+
+// appendImage appends the dot lines of the given image to the output.
+func (e *Emulator) appendImage(img *bitmap.Image) {
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		row := make([]byte, (e.width+7)/8)
+		for x := 0; x < e.width; x++ {
+			if !img.BitAt(x, y) {
+				row[x/8] |= 1 << (7 - x%8)
+			}
+		}
+		e.rows = append(e.rows, row)
+	}
+}
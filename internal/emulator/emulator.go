@@ -0,0 +1,171 @@
+package emulator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// A command decodes the arguments of a single printer command and applies its effects to the emulator.
+type command func(e *Emulator, r *bufio.Reader) error
+
+// commands maps each supported two-byte command prefix to its decoder.
+var commands = map[[2]byte]command{
+	{0x10, 0x04}: skip(1),                    // DLE EOT n; the emulator cannot answer status queries
+	{0x12, 0x23}: skip(1),                    // DC2 # n
+	{0x12, 0x2a}: (*Emulator).rasterBitImage, // DC2 * r n [d1...dn]
+	{0x1b, 0x37}: skip(3),                    // ESC 7 n1 n2 n3
+	{0x1b, 0x40}: (*Emulator).initialize,     // ESC @
+	{0x1b, 0x4a}: (*Emulator).feed,           // ESC J n
+	{0x1b, 0x61}: (*Emulator).justification,  // ESC a n
+	{0x1d, 0x48}: (*Emulator).hriPosition,    // GS H n
+	{0x1d, 0x68}: (*Emulator).barcodeHeight,  // GS h n
+	{0x1d, 0x6b}: (*Emulator).barcode,        // GS k m n [d1...dn]
+	{0x1d, 0x77}: (*Emulator).moduleWidth,    // GS w n
+}
+
+// An Emulator reconstructs the output of a thermal printer from the command stream that was sent to it.
+type Emulator struct {
+	width int
+	rows  [][]byte // Each row holds one packed dot line; a set bit corresponds to a black dot.
+	pos   int64    // The offset of the current command in the stream.
+	state state
+}
+
+// state holds the settings that are changed by printer commands and restored by ESC @.
+type state struct {
+	justification byte // 0 for left, 1 for center, and 2 for right. Only applies to barcodes.
+	hriPosition   byte // The position of a barcode's human-readable text. Only 0 (none) and 2 (below) are drawn.
+	barcodeHeight int  // The height of a barcode's bars in dots.
+	moduleWidth   int  // The width of a barcode's narrowest bar in dots.
+}
+
+// defaultState holds the power-on settings.
+var defaultState = state{barcodeHeight: 162, moduleWidth: 3}
+
+// New creates a new Emulator for a printer with the given width in dots.
+func New(width int) *Emulator {
+	return &Emulator{width: width, state: defaultState}
+}
+
+// Decode runs a new Emulator of the given width over the command stream read from r and returns the printed output.
+func Decode(r io.Reader, width int) (*bitmap.Image, error) {
+	e := New(width)
+	if err := e.Run(r); err != nil {
+		return nil, err
+	}
+	return e.Image(), nil
+}
+
+// Run decodes and applies commands read from r until r returns io.EOF.
+func (e *Emulator) Run(r io.Reader) error {
+	br := bufio.NewReader(&countingReader{r: r, n: &e.pos})
+	for {
+		start := e.pos - int64(br.Buffered())
+
+		var prefix [2]byte
+		if _, err := io.ReadFull(br, prefix[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return e.errorf(start, "reading command: %v", unexpectedEOF(err))
+		}
+
+		cmd, ok := commands[prefix]
+		if !ok {
+			return e.errorf(start, "unsupported command 0x%02x 0x%02x", prefix[0], prefix[1])
+		}
+		if err := cmd(e, br); err != nil {
+			return e.errorf(start, "%v", unexpectedEOF(err))
+		}
+	}
+}
+
+// Image returns the output printed so far. Set bits in the result correspond to white; unset bits correspond to
+// black.
+func (e *Emulator) Image() *bitmap.Image {
+	img := bitmap.New(image.Rect(0, 0, e.width, len(e.rows)))
+	for y, row := range e.rows {
+		for x := 0; x < e.width; x++ {
+			byteOffset, bitOffset := x/8, 7-x%8
+			black := byteOffset < len(row) && row[byteOffset]&(1<<bitOffset) != 0
+			img.SetBit(x, y, !black)
+		}
+	}
+	return img
+}
+
+func (e *Emulator) errorf(offset int64, format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", offset, fmt.Sprintf(format, args...))
+}
+
+// skip returns a decoder for commands whose n argument bytes have no visible effect on the output, such as commands
+// that reset the printer, configure its print head, or query its status.
+func skip(n int) command {
+	return func(e *Emulator, r *bufio.Reader) error {
+		_, err := r.Discard(n)
+		return err
+	}
+}
+
+// rasterBitImage decodes a DC2 * command, which prints r rows of n bytes each.
+func (e *Emulator) rasterBitImage(r *bufio.Reader) error {
+	var args [2]byte
+	if _, err := io.ReadFull(r, args[:]); err != nil {
+		return err
+	}
+	rows, width := int(args[0]), int(args[1])
+	if width > (e.width+7)/8 {
+		return fmt.Errorf("raster rows of %d dots exceed the printer width of %d dots", width*8, e.width)
+	}
+
+	for i := 0; i < rows; i++ {
+		row := make([]byte, width)
+		if _, err := io.ReadFull(r, row); err != nil {
+			return err
+		}
+		e.rows = append(e.rows, row)
+	}
+	return nil
+}
+
+// initialize decodes an ESC @ command, which restores the printer's power-on settings.
+func (e *Emulator) initialize(r *bufio.Reader) error {
+	e.state = defaultState
+	return nil
+}
+
+// feed decodes an ESC J command, which feeds the paper by n dot lines.
+func (e *Emulator) feed(r *bufio.Reader) error {
+	n, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(n); i++ {
+		e.rows = append(e.rows, nil)
+	}
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// countingReader counts the bytes read from an io.Reader.
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	*c.n += int64(n)
+	return n, err
+}
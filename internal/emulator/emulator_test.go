@@ -0,0 +1,330 @@
+package emulator
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+	"github.com/pgavlin/lilprinty/internal/printer"
+)
+
+// newDevice returns a printer.Device for the given profile that records its output in the returned buffer.
+func newDevice(profile printer.Profile) (*printer.Device, *bytes.Buffer) {
+	config := printer.DefaultConfig
+	config.Profile = profile
+	config.BaudRate, config.DotPrintTime, config.DotFeedTime = 0, 0, 0
+
+	var output bytes.Buffer
+	return printer.New(&output, config), &output
+}
+
+// newImage returns a white image of the given size.
+func newImage(width, height int) *bitmap.Image {
+	img := bitmap.New(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	return img
+}
+
+// fillRect draws a black rectangle into the given image.
+func fillRect(img *bitmap.Image, r image.Rectangle) {
+	draw.Draw(img, r, image.NewUniform(color.Black), image.Point{}, draw.Src)
+}
+
+// drawBars draws the bars of the given one-dimensional barcode into img with its upper-left corner at the given point.
+func drawBars(img *bitmap.Image, code image.Image, at image.Point, moduleWidth, height int) {
+	for x := 0; x < code.Bounds().Dx(); x++ {
+		if code.At(x, 0) == color.Black {
+			fillRect(img, image.Rect(at.X+x*moduleWidth, at.Y, at.X+(x+1)*moduleWidth, at.Y+height))
+		}
+	}
+}
+
+// checkImage fails the test if the two images differ.
+func checkImage(t *testing.T, expected, actual *bitmap.Image) {
+	t.Helper()
+
+	if expected.Bounds() != actual.Bounds() {
+		t.Fatalf("expected bounds %v, got %v", expected.Bounds(), actual.Bounds())
+	}
+	for y := 0; y < expected.Bounds().Dy(); y++ {
+		for x := 0; x < expected.Bounds().Dx(); x++ {
+			if expected.BitAt(x, y) != actual.BitAt(x, y) {
+				t.Fatalf("images differ at (%v, %v)", x, y)
+			}
+		}
+	}
+}
+
+// decode runs the given command stream through an emulator of the given width.
+func decode(t *testing.T, stream []byte, width int) *bitmap.Image {
+	t.Helper()
+
+	img, err := Decode(bytes.NewReader(stream), width)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestBitmapsAndFeeds(t *testing.T) {
+	profile := printer.Profile{Width: 64, DPI: 203.2, LeftMargin: 5, RightMargin: 3}
+	device, output := newDevice(profile)
+
+	// An L-shaped mark in the corner of a bitmap that is not a multiple of eight dots wide.
+	mark := newImage(13, 4)
+	fillRect(mark, image.Rect(0, 0, 1, 4))
+	fillRect(mark, image.Rect(0, 3, 13, 4))
+
+	if err := device.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.PrintBitmap(mark); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.Feed(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.PrintBitmap(mark); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each bitmap is offset by the left margin, and the feed leaves blank lines between them.
+	expected := newImage(profile.Width, 11)
+	for _, y := range []int{0, 7} {
+		fillRect(expected, image.Rect(5, y, 6, y+4))
+		fillRect(expected, image.Rect(5, y+3, 18, y+4))
+	}
+	checkImage(t, expected, decode(t, output.Bytes(), profile.Width))
+}
+
+func TestBarcodes(t *testing.T) {
+	profile := printer.Profile58mm
+
+	cases := []struct {
+		name   string
+		code   func() (image.Image, error)
+		native bitmap.Barcode
+	}{
+		{
+			name: "code128",
+			code: func() (image.Image, error) { return code128.Encode("SHELF-A12") },
+			native: bitmap.Barcode{
+				Symbology: "code128", Data: "SHELF-A12", ModuleWidth: 2, Height: 20,
+			},
+		},
+		{
+			name: "code128 numeric",
+			code: func() (image.Image, error) { return code128.Encode("AB123456") },
+			native: bitmap.Barcode{
+				Symbology: "code128", Data: "AB123456", ModuleWidth: 3, Height: 20,
+			},
+		},
+		{
+			name: "ean13",
+			code: func() (image.Image, error) { return ean.Encode("4006381333931") },
+			native: bitmap.Barcode{
+				Symbology: "ean13", Data: "4006381333931", Modules: 95, ModuleWidth: 2, Height: 30,
+			},
+		},
+		{
+			name: "upca",
+			code: func() (image.Image, error) { return ean.Encode("0036000291452") },
+			native: bitmap.Barcode{
+				Symbology: "upca", Data: "036000291452", Modules: 95, ModuleWidth: 2, Height: 30,
+			},
+		},
+	}
+	for _, c := range cases {
+		for _, printText := range []bool{false, true} {
+			name := c.name
+			if printText {
+				name += " with text"
+			}
+			t.Run(name, func(t *testing.T) {
+				code, err := c.code()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// The raster form holds the centered bars, followed by a caption if the text is printed.
+				b := c.native
+				height := b.Height
+				if printText {
+					height += 12
+				}
+				b.PrintText, b.Modules = printText, code.Bounds().Dx()
+				b.Raster = newImage(profile.Width, height)
+				drawBars(b.Raster, code, image.Point{(profile.Width - b.Modules*b.ModuleWidth) / 2, 0}, b.ModuleWidth,
+					b.Height)
+				if printText {
+					fillRect(b.Raster, image.Rect(150, b.Height+2, 230, b.Height+10))
+				}
+
+				device, output := newDevice(profile)
+				if !device.SupportsBarcode(b) {
+					t.Fatal("barcode is not supported")
+				}
+				if err := device.PrintBarcode(b); err != nil {
+					t.Fatal(err)
+				}
+
+				// The native barcode must match its raster form exactly.
+				checkImage(t, b.Raster, decode(t, output.Bytes(), profile.Width))
+			})
+		}
+	}
+}
+
+func TestBarcodeSettings(t *testing.T) {
+	const width = 256
+	code, err := code128.Encode("AB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules := code.Bounds().Dx()
+
+	// GS k 73 n {B A B prints "AB" in code set B.
+	barcode := []byte{0x1d, 0x6b, 73, 4, '{', 'B', 'A', 'B'}
+	command := func(prefix ...byte) []byte {
+		return append(prefix, barcode...)
+	}
+
+	cases := []struct {
+		name           string
+		stream         []byte
+		left, height   int
+		moduleWidth    int
+		hri            bool
+		expectedHeight int
+	}{
+		{name: "defaults", stream: command(), height: 162, moduleWidth: 3},
+		{name: "height and width", stream: command(0x1d, 0x68, 10, 0x1d, 0x77, 2), height: 10, moduleWidth: 2},
+		{
+			name:        "centered",
+			stream:      command(0x1d, 0x68, 10, 0x1b, 0x61, 1),
+			left:        (width - 3*modules) / 2,
+			height:      10,
+			moduleWidth: 3,
+		},
+		{
+			name:        "right",
+			stream:      command(0x1d, 0x68, 10, 0x1b, 0x61, '2'),
+			left:        width - 3*modules,
+			height:      10,
+			moduleWidth: 3,
+		},
+		{
+			name:        "human-readable text",
+			stream:      command(0x1d, 0x68, 10, 0x1d, 0x48, 2),
+			height:      10,
+			moduleWidth: 3,
+			hri:         true,
+		},
+		{
+			name:        "reset",
+			stream:      command(0x1d, 0x68, 10, 0x1d, 0x77, 2, 0x1b, 0x61, 2, 0x1d, 0x48, 2, 0x1b, 0x40),
+			height:      162,
+			moduleWidth: 3,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := decode(t, c.stream, width)
+
+			height := c.height
+			if c.hri {
+				height += hriHeight
+			}
+			expected := newImage(width, height)
+			drawBars(expected, code, image.Point{c.left, 0}, c.moduleWidth, c.height)
+
+			// The text is drawn beneath the bars; only its presence is checked.
+			if c.hri {
+				if actual.Bounds() != expected.Bounds() {
+					t.Fatalf("expected bounds %v, got %v", expected.Bounds(), actual.Bounds())
+				}
+				text := actual.Bounds()
+				text.Min.Y = c.height
+				blank := true
+				for y := text.Min.Y; y < text.Max.Y && blank; y++ {
+					for x := text.Min.X; x < text.Max.X && blank; x++ {
+						blank = actual.BitAt(x, y)
+					}
+				}
+				if blank {
+					t.Fatal("expected human-readable text")
+				}
+				draw.Draw(actual, text, image.NewUniform(color.White), image.Point{}, draw.Src)
+			}
+			checkImage(t, expected, actual)
+		})
+	}
+}
+
+func TestDecodeCode128(t *testing.T) {
+	cases := []struct {
+		data, expected, err string
+	}{
+		{data: "{BSHELF-A12", expected: "SHELF-A12"},
+		{data: "{ASHELF", expected: "SHELF"},
+		{data: "{Ba{{b", expected: "a{b"},
+		{data: "{C\x0c\x22{B5", expected: "12345"},
+		{data: "{C\x00\x63", expected: "0099"},
+		{data: "SHELF", err: "must begin with a code set selector"},
+		{data: "{B{", err: "incomplete Code 128 escape"},
+		{data: "{B{X", err: "unsupported Code 128 escape"},
+		{data: "{C\x64", err: "invalid Code 128 code set C value"},
+	}
+	for _, c := range cases {
+		actual, err := decodeCode128([]byte(c.data))
+		switch {
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%q: expected error %q, got %v", c.data, c.err, err)
+		case c.err == "" && err != nil:
+			t.Errorf("%q: unexpected error: %v", c.data, err)
+		case actual != c.expected:
+			t.Errorf("%q: expected %q, got %q", c.data, c.expected, actual)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		stream []byte
+		err    string
+	}{
+		{name: "unknown command", stream: []byte{0x1b, 0x40, 0x1b, 0x00}, err: "offset 2: unsupported command 0x1b 0x00"},
+		{name: "truncated prefix", stream: []byte{0x1b, 0x40, 0x1b}, err: "offset 2: reading command: " + io.ErrUnexpectedEOF.Error()},
+		{name: "truncated feed", stream: []byte{0x1b, 0x4a}, err: "offset 0: " + io.ErrUnexpectedEOF.Error()},
+		{name: "truncated heat settings", stream: []byte{0x1b, 0x37, 11, 120}, err: "offset 0: " + io.ErrUnexpectedEOF.Error()},
+		{name: "truncated density", stream: []byte{0x12, 0x23}, err: "offset 0: " + io.ErrUnexpectedEOF.Error()},
+		{name: "truncated raster header", stream: []byte{0x12, 0x2a, 1}, err: "offset 0: " + io.ErrUnexpectedEOF.Error()},
+		{name: "truncated raster row", stream: []byte{0x12, 0x2a, 2, 2, 0xff, 0xff, 0xff}, err: "offset 0: " + io.ErrUnexpectedEOF.Error()},
+		{name: "truncated justification", stream: []byte{0x1b, 0x61}, err: "offset 0: " + io.ErrUnexpectedEOF.Error()},
+		{name: "truncated barcode", stream: []byte{0x1d, 0x6b, 73, 4, '{', 'B'}, err: "offset 0: " + io.ErrUnexpectedEOF.Error()},
+		{name: "raster too wide", stream: []byte{0x12, 0x2a, 1, 33}, err: "exceed the printer width of 256 dots"},
+		{name: "zero barcode height", stream: []byte{0x1d, 0x68, 0}, err: "barcode height must be at least 1 dot"},
+		{name: "module width", stream: []byte{0x1d, 0x77, 7}, err: "barcode module width must be in the range [2, 6]"},
+		{name: "barcode system", stream: []byte{0x1d, 0x6b, 4, 0}, err: "unsupported barcode system 4"},
+		{name: "barcode too wide", stream: []byte{0x1d, 0x77, 6, 0x1d, 0x6b, 73, 8, '{', 'B', 'A', 'B', 'C', 'D', 'E', 'F'},
+			err: "exceeds the printer width"},
+		{name: "EAN-13 digits", stream: []byte{0x1d, 0x6b, 67, 3, '1', '2', '3'}, err: "EAN-13 barcodes must have 12 or 13 digits"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(c.stream), 256)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error %q, got %v", c.err, err)
+			}
+		})
+	}
+}
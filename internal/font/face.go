@@ -1,11 +1,19 @@
 package font
 
-import "golang.org/x/image/font"
+import (
+	"image"
+	"image/draw"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
 
 type Face struct {
 	font.Face
 
 	faceFamily   *FaceFamily
+	typeface     *Typeface
 	bold, italic bool
 }
 
@@ -40,3 +48,69 @@ func (f *Face) WithBold(bold bool) *Face {
 func (f *Face) WithItalic(italic bool) *Face {
 	return f.faceFamily.Face(f.bold, italic)
 }
+
+// HasGlyph returns true if the face has a glyph for the given rune.
+func (f *Face) HasGlyph(r rune) bool {
+	if face, ok := f.Face.(*sfntFace); ok {
+		_, ok = face.index(r)
+		return ok
+	}
+	return f.typeface.trueType.Index(r) != 0
+}
+
+// replaced returns true if the face draws a replacement box in place of the given rune. Visible characters that the
+// face lacks are replaced; spaces and control characters are not.
+func (f *Face) replaced(r rune) bool {
+	return unicode.IsGraphic(r) && !unicode.IsSpace(r) && !f.HasGlyph(r)
+}
+
+// replacementBox returns the outline of the replacement box relative to the dot, the thickness of its lines in
+// pixels, and its advance width. The box is as tall as three quarters of the face's ascent.
+func (f *Face) replacementBox() (bounds fixed.Rectangle26_6, thickness int, advance fixed.Int26_6) {
+	ascent := f.Face.Metrics().Ascent
+	height := ascent * 3 / 4
+	width, bearing := height*5/8, height/8
+
+	bounds = fixed.Rectangle26_6{
+		Min: fixed.Point26_6{X: bearing, Y: -height},
+		Max: fixed.Point26_6{X: bearing + width, Y: 0},
+	}
+	return bounds, (ascent / 12).Ceil(), width + 2*bearing
+}
+
+// Glyph returns the glyph for the given rune. Visible characters that the face lacks are drawn as a replacement box.
+func (f *Face) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	if !f.replaced(r) {
+		return f.Face.Glyph(dot, r)
+	}
+
+	bounds, thickness, advance := f.replacementBox()
+	dr = image.Rect(
+		(dot.X + bounds.Min.X).Round(), (dot.Y + bounds.Min.Y).Round(),
+		(dot.X + bounds.Max.X).Round(), (dot.Y + bounds.Max.Y).Round())
+
+	box := image.NewAlpha(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	draw.Draw(box, box.Bounds(), image.Opaque, image.Point{}, draw.Src)
+	draw.Draw(box, box.Bounds().Inset(thickness), image.Transparent, image.Point{}, draw.Src)
+	return dr, box, image.Point{}, advance, true
+}
+
+// GlyphBounds returns the bounds of the glyph for the given rune. Visible characters that the face lacks are measured
+// as a replacement box.
+func (f *Face) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	if !f.replaced(r) {
+		return f.Face.GlyphBounds(r)
+	}
+	bounds, _, advance = f.replacementBox()
+	return bounds, advance, true
+}
+
+// GlyphAdvance returns the advance width of the glyph for the given rune. Visible characters that the face lacks are
+// measured as a replacement box.
+func (f *Face) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	if !f.replaced(r) {
+		return f.Face.GlyphAdvance(r)
+	}
+	_, _, advance = f.replacementBox()
+	return advance, true
+}
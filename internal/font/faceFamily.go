@@ -1,7 +1,5 @@
 package font
 
-import "github.com/golang/freetype/truetype"
-
 type FaceFamily struct {
 	family    *Family
 	pointSize float64
@@ -41,7 +39,8 @@ func (ff *FaceFamily) Regular() *Face {
 		opts := ff.family.options
 		opts.Size = ff.pointSize
 		ff.regularFace = &Face{
-			Face:       truetype.NewFace(ff.family.regularFont, &opts),
+			Face:       ff.family.regularFont.newFace(opts),
+			typeface:   ff.family.regularFont,
 			faceFamily: ff,
 		}
 	}
@@ -53,7 +52,8 @@ func (ff *FaceFamily) Bold() *Face {
 		opts := ff.family.options
 		opts.Size = ff.pointSize
 		ff.boldFace = &Face{
-			Face:       truetype.NewFace(ff.family.boldFont, &opts),
+			Face:       ff.family.boldFont.newFace(opts),
+			typeface:   ff.family.boldFont,
 			faceFamily: ff,
 			bold:       true,
 		}
@@ -66,7 +66,8 @@ func (ff *FaceFamily) Italic() *Face {
 		opts := ff.family.options
 		opts.Size = ff.pointSize
 		ff.italicFace = &Face{
-			Face:       truetype.NewFace(ff.family.italicFont, &opts),
+			Face:       ff.family.italicFont.newFace(opts),
+			typeface:   ff.family.italicFont,
 			faceFamily: ff,
 			italic:     true,
 		}
@@ -79,7 +80,8 @@ func (ff *FaceFamily) BoldItalic() *Face {
 		opts := ff.family.options
 		opts.Size = ff.pointSize
 		ff.boldItalicFace = &Face{
-			Face:       truetype.NewFace(ff.family.boldItalicFont, &opts),
+			Face:       ff.family.boldItalicFont.newFace(opts),
+			typeface:   ff.family.boldItalicFont,
 			faceFamily: ff,
 			bold:       true,
 			italic:     true,
@@ -9,40 +9,46 @@ import (
 type Family struct {
 	options truetype.Options
 
-	regularFont    *truetype.Font
-	boldFont       *truetype.Font
-	italicFont     *truetype.Font
-	boldItalicFont *truetype.Font
+	regularFont    *Typeface
+	boldFont       *Typeface
+	italicFont     *Typeface
+	boldItalicFont *Typeface
 
 	sizes map[float64]*FaceFamily
 }
 
+// NewFamily returns a family with the given typefaces.
+func NewFamily(regular, bold, italic, boldItalic *Typeface, options truetype.Options) *Family {
+	return &Family{
+		options:        options,
+		regularFont:    regular,
+		boldFont:       bold,
+		italicFont:     italic,
+		boldItalicFont: boldItalic,
+		sizes:          map[float64]*FaceFamily{},
+	}
+}
+
+// ParseFamily parses the typefaces of a family. See ParseTypeface for the supported formats.
 func ParseFamily(regular, bold, italic, boldItalic []byte, options truetype.Options) (*Family, error) {
-	regularFont, err := truetype.Parse(regular)
+	regularFont, err := ParseTypeface(regular, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse regular font: %w", err)
 	}
-	boldFont, err := truetype.Parse(bold)
+	boldFont, err := ParseTypeface(bold, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse bold font: %w", err)
 	}
-	italicFont, err := truetype.Parse(italic)
+	italicFont, err := ParseTypeface(italic, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse italic font: %w", err)
 	}
-	boldItalicFont, err := truetype.Parse(boldItalic)
+	boldItalicFont, err := ParseTypeface(boldItalic, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse boldItalic font: %w", err)
 	}
 
-	return &Family{
-		options:        options,
-		regularFont:    regularFont,
-		boldFont:       boldFont,
-		italicFont:     italicFont,
-		boldItalicFont: boldItalicFont,
-		sizes:          map[float64]*FaceFamily{},
-	}, nil
+	return NewFamily(regularFont, boldFont, italicFont, boldItalicFont, options), nil
 }
 
 func (f *Family) Size(pointSize float64) *FaceFamily {
@@ -0,0 +1,172 @@
+package font
+
+import (
+	"image"
+	"math"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// sfntFace implements font.Face for fonts that freetype cannot rasterize, such as OpenType fonts with PostScript
+// outlines. Glyphs are rasterized from their outlines without hinting.
+type sfntFace struct {
+	font   *sfnt.Font
+	ppem   fixed.Int26_6
+	bounds fixed.Rectangle26_6
+
+	// valid records whether each glyph can be rasterized. Glyphs without outlines, such as colored emoji, cannot.
+	// Neither can glyphs whose outlines stray outside the font's bounding box: the sfnt package misreads some
+	// PostScript outlines (notably those with fractional coordinates), producing enormous glyphs.
+	valid map[sfnt.GlyphIndex]bool
+
+	buf        sfnt.Buffer
+	rasterizer vector.Rasterizer
+}
+
+func newSFNTFace(f *sfnt.Font, options truetype.Options) *sfntFace {
+	size, dpi := options.Size, options.DPI
+	if size == 0 {
+		size = 12
+	}
+	if dpi == 0 {
+		dpi = 72
+	}
+	face := &sfntFace{
+		font:  f,
+		ppem:  fixed.Int26_6(math.Round(size * dpi / 72 * 64)),
+		valid: map[sfnt.GlyphIndex]bool{},
+	}
+
+	// Allow a pixel of slack around the bounding box for rounding.
+	bounds, err := f.Bounds(&face.buf, face.ppem, font.HintingNone)
+	if err == nil {
+		face.bounds = fixed.Rectangle26_6{
+			Min: bounds.Min.Sub(fixed.P(1, 1)),
+			Max: bounds.Max.Add(fixed.P(1, 1)),
+		}
+	}
+	return face
+}
+
+// index returns the index of the glyph for the given rune. It returns false if the font does not contain a usable glyph
+// for the rune.
+func (f *sfntFace) index(r rune) (sfnt.GlyphIndex, bool) {
+	x, err := f.font.GlyphIndex(&f.buf, r)
+	if err != nil || x == 0 {
+		return 0, false
+	}
+
+	valid, ok := f.valid[x]
+	if !ok {
+		bounds, _, err := f.font.GlyphBounds(&f.buf, x, f.ppem, font.HintingNone)
+		valid = err == nil && bounds.Min.In(f.bounds) && bounds.Max.In(f.bounds)
+		if valid {
+			// Colored glyphs, such as emoji, have no outline to rasterize.
+			_, err = f.font.LoadGlyph(&f.buf, x, f.ppem, nil)
+			valid = err == nil
+		}
+		f.valid[x] = valid
+	}
+	return x, valid
+}
+
+func (f *sfntFace) Close() error {
+	return nil
+}
+
+func (f *sfntFace) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	x, ok := f.index(r)
+	if !ok {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+
+	bounds, advance, err := f.font.GlyphBounds(&f.buf, x, f.ppem, font.HintingNone)
+	if err != nil {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+	segments, err := f.font.LoadGlyph(&f.buf, x, f.ppem, nil)
+	if err != nil {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+
+	dr = image.Rect(
+		(dot.X + bounds.Min.X).Floor(), (dot.Y + bounds.Min.Y).Floor(),
+		(dot.X + bounds.Max.X).Ceil(), (dot.Y + bounds.Max.Y).Ceil())
+	if dr.Empty() {
+		return dr, image.NewAlpha(image.Rectangle{}), image.Point{}, advance, true
+	}
+
+	// Segment coordinates are relative to the dot; translate them into the mask's coordinate space.
+	originX := float32(dot.X-fixed.I(dr.Min.X)) / 64
+	originY := float32(dot.Y-fixed.I(dr.Min.Y)) / 64
+	point := func(p fixed.Point26_6) (float32, float32) {
+		return originX + float32(p.X)/64, originY + float32(p.Y)/64
+	}
+
+	f.rasterizer.Reset(dr.Dx(), dr.Dy())
+	for _, s := range segments {
+		switch s.Op {
+		case sfnt.SegmentOpMoveTo:
+			f.rasterizer.MoveTo(point(s.Args[0]))
+		case sfnt.SegmentOpLineTo:
+			f.rasterizer.LineTo(point(s.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			x1, y1 := point(s.Args[0])
+			x2, y2 := point(s.Args[1])
+			f.rasterizer.QuadTo(x1, y1, x2, y2)
+		case sfnt.SegmentOpCubeTo:
+			x1, y1 := point(s.Args[0])
+			x2, y2 := point(s.Args[1])
+			x3, y3 := point(s.Args[2])
+			f.rasterizer.CubeTo(x1, y1, x2, y2, x3, y3)
+		}
+	}
+	f.rasterizer.ClosePath()
+
+	alpha := image.NewAlpha(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	f.rasterizer.Draw(alpha, alpha.Bounds(), image.Opaque, image.Point{})
+	return dr, alpha, image.Point{}, advance, true
+}
+
+func (f *sfntFace) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	x, ok := f.index(r)
+	if !ok {
+		return fixed.Rectangle26_6{}, 0, false
+	}
+	bounds, advance, err := f.font.GlyphBounds(&f.buf, x, f.ppem, font.HintingNone)
+	return bounds, advance, err == nil
+}
+
+func (f *sfntFace) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	x, ok := f.index(r)
+	if !ok {
+		return 0, false
+	}
+	advance, err := f.font.GlyphAdvance(&f.buf, x, f.ppem, font.HintingNone)
+	return advance, err == nil
+}
+
+func (f *sfntFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	x0, ok0 := f.index(r0)
+	x1, ok1 := f.index(r1)
+	if !ok0 || !ok1 {
+		return 0
+	}
+	kern, err := f.font.Kern(&f.buf, x0, x1, f.ppem, font.HintingNone)
+	if err != nil {
+		return 0
+	}
+	return kern
+}
+
+func (f *sfntFace) Metrics() font.Metrics {
+	metrics, err := f.font.Metrics(&f.buf, f.ppem, font.HintingNone)
+	if err != nil {
+		return font.Metrics{}
+	}
+	return metrics
+}
@@ -0,0 +1,227 @@
+package font
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	woff "github.com/tdewolff/canvas/font"
+	"golang.org/x/image/font/sfnt"
+)
+
+// A SystemFont describes a font installed in one of the system's font directories.
+type SystemFont struct {
+	Path  string // The path to the font file.
+	Index int    // The index of the font within the file, if the file is a font collection.
+
+	Family    string // The font's family name, e.g. "DejaVu Sans".
+	Subfamily string // The font's subfamily name, e.g. "Bold Oblique".
+	FullName  string // The font's full name, e.g. "DejaVu Sans Bold Oblique".
+
+	// TypographicFamily and TypographicSubfamily are the font's preferred family and subfamily names, if they differ
+	// from Family and Subfamily, e.g. "Source Sans Pro" and "Light" for a font whose family is "Source Sans Pro Light".
+	TypographicFamily    string
+	TypographicSubfamily string
+}
+
+// Load reads and parses the font.
+func (f *SystemFont) Load() (*Typeface, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTypeface(data, f.Index)
+}
+
+// style returns the style described by the given subfamily name. It returns false if the name describes anything
+// other than a combination of weight and slope that fits a four-style family, e.g. "Light" or "Condensed Bold".
+func style(subfamily string) (bold, italic, ok bool) {
+	for _, word := range strings.Fields(strings.ToLower(subfamily)) {
+		switch word {
+		case "regular", "book", "normal", "roman", "plain":
+		case "bold":
+			bold = true
+		case "italic", "oblique":
+			italic = true
+		default:
+			return false, false, false
+		}
+	}
+	return bold, italic, true
+}
+
+// hasStyle returns true if the font is the given style of the given family.
+func (f *SystemFont) hasStyle(family string, bold, italic bool) bool {
+	matches := func(familyName, subfamilyName string) bool {
+		if familyName == "" || !strings.EqualFold(familyName, family) {
+			return false
+		}
+		b, i, ok := style(subfamilyName)
+		return ok && b == bold && i == italic
+	}
+	return matches(f.Family, f.Subfamily) || matches(f.TypographicFamily, f.TypographicSubfamily)
+}
+
+// systemFontDirs returns the directories searched for fonts. These are the default directories searched by
+// fontconfig on the current platform.
+func systemFontDirs() []string {
+	home, _ := os.UserHomeDir()
+
+	switch runtime.GOOS {
+	case "windows":
+		dirs := []string{filepath.Join(os.Getenv("WINDIR"), "Fonts")}
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			dirs = append(dirs, filepath.Join(local, "Microsoft", "Windows", "Fonts"))
+		}
+		return dirs
+	case "darwin":
+		dirs := []string{"/System/Library/Fonts", "/Library/Fonts", "/Network/Library/Fonts"}
+		if home != "" {
+			dirs = append(dirs, filepath.Join(home, "Library", "Fonts"))
+		}
+		return dirs
+	}
+
+	var dirs []string
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" && home != "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	if dataHome != "" {
+		dirs = append(dirs, filepath.Join(dataHome, "fonts"))
+	}
+	if home != "" {
+		dirs = append(dirs, filepath.Join(home, ".fonts"))
+	}
+
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range filepath.SplitList(dataDirs) {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "fonts"))
+		}
+	}
+	return dirs
+}
+
+var systemFonts struct {
+	once  sync.Once
+	fonts []*SystemFont
+}
+
+// SystemFonts returns the fonts installed in the system's font directories. The directories are scanned on the
+// first call; later calls return the same result.
+func SystemFonts() []*SystemFont {
+	systemFonts.once.Do(func() {
+		for _, dir := range systemFontDirs() {
+			systemFonts.fonts = append(systemFonts.fonts, scanFontDir(dir)...)
+		}
+	})
+	return systemFonts.fonts
+}
+
+// scanFontDir returns the fonts in the given directory and its subdirectories. Files that cannot be read or parsed
+// are ignored.
+func scanFontDir(dir string) []*SystemFont {
+	var fonts []*SystemFont
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ttf", ".otf", ".ttc", ".otc", ".woff", ".woff2":
+			fonts = append(fonts, readFontNames(path)...)
+		}
+		return nil
+	})
+	return fonts
+}
+
+// readFontNames returns a description of each font in the given file.
+func readFontNames(path string) []*SystemFont {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var fonts []*sfnt.Font
+	if bytes.HasPrefix(data, []byte("ttcf")) {
+		collection, err := sfnt.ParseCollection(data)
+		if err != nil {
+			return nil
+		}
+		for i := 0; i < collection.NumFonts(); i++ {
+			f, err := collection.Font(i)
+			if err != nil {
+				return nil
+			}
+			fonts = append(fonts, f)
+		}
+	} else {
+		if data, err = woff.ToSFNT(data); err != nil {
+			return nil
+		}
+		f, err := sfnt.Parse(data)
+		if err != nil {
+			return nil
+		}
+		fonts = append(fonts, f)
+	}
+
+	var buf sfnt.Buffer
+	name := func(f *sfnt.Font, id sfnt.NameID) string {
+		s, _ := f.Name(&buf, id)
+		return s
+	}
+
+	result := make([]*SystemFont, len(fonts))
+	for i, f := range fonts {
+		result[i] = &SystemFont{
+			Path:                 path,
+			Index:                i,
+			Family:               name(f, sfnt.NameIDFamily),
+			Subfamily:            name(f, sfnt.NameIDSubfamily),
+			FullName:             name(f, sfnt.NameIDFull),
+			TypographicFamily:    name(f, sfnt.NameIDTypographicFamily),
+			TypographicSubfamily: name(f, sfnt.NameIDTypographicSubfamily),
+		}
+	}
+	return result
+}
+
+// FindSystemFont returns the installed font with the given name. The name may be a font's full name, e.g. "DejaVu
+// Sans Bold", or a family name, in which case the family's regular style is returned. Names are compared without
+// regard to case.
+func FindSystemFont(name string) (*SystemFont, error) {
+	fonts := SystemFonts()
+	for _, f := range fonts {
+		if strings.EqualFold(f.FullName, name) {
+			return f, nil
+		}
+	}
+	for _, f := range fonts {
+		if f.hasStyle(name, false, false) {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("no installed font is named %q", name)
+}
+
+// FindSystemFamilyStyle returns the installed font for the given style of the given family. It returns nil if the
+// family has no such style.
+func FindSystemFamilyStyle(family string, bold, italic bool) *SystemFont {
+	for _, f := range SystemFonts() {
+		if f.hasStyle(family, bold, italic) {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package font
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/freetype/truetype"
+	woff "github.com/tdewolff/canvas/font"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+)
+
+// A Typeface is a single parsed font, such as the bold style of a family.
+type Typeface struct {
+	// trueType is set for fonts with TrueType outlines, which are rasterized by freetype.
+	trueType *truetype.Font
+	// sfnt is set for other OpenType fonts, such as those with PostScript (CFF) outlines.
+	sfnt *sfnt.Font
+}
+
+// ParseTypeface parses a TrueType, OpenType, WOFF, or WOFF2 font. If the data is a font collection, index selects the
+// font to use; otherwise, index is ignored.
+func ParseTypeface(data []byte, index int) (*Typeface, error) {
+	if bytes.HasPrefix(data, []byte("ttcf")) {
+		collection, err := sfnt.ParseCollection(data)
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= collection.NumFonts() {
+			return nil, fmt.Errorf("font index %v is out of range: the collection contains %v fonts", index, collection.NumFonts())
+		}
+		f, err := collection.Font(index)
+		if err != nil {
+			return nil, err
+		}
+		return &Typeface{sfnt: f}, nil
+	}
+
+	data, err := woff.ToSFNT(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// freetype only understands TrueType outlines, so fall back to sfnt for anything it rejects.
+	if f, err := truetype.Parse(data); err == nil {
+		return &Typeface{trueType: f}, nil
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Typeface{sfnt: f}, nil
+}
+
+// newFace returns a face that renders the typeface using the given options.
+func (t *Typeface) newFace(options truetype.Options) font.Face {
+	if t.trueType != nil {
+		return truetype.NewFace(t.trueType, &options)
+	}
+	return newSFNTFace(t.sfnt, options)
+}
@@ -0,0 +1,252 @@
+// Package highlight splits source code into tokens for syntax highlighting.
+package highlight
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Kind identifies the kind of a token.
+type Kind string
+
+const (
+	Plain   Kind = "plain"   // Identifiers, operators, punctuation, and whitespace.
+	Keyword Kind = "keyword" // Keywords and literals such as true and null.
+	Comment Kind = "comment" // Line and block comments.
+	String  Kind = "string"  // String and character literals.
+	Number  Kind = "number"  // Numeric literals.
+)
+
+// Kinds lists each kind of token.
+var Kinds = []Kind{Plain, Keyword, Comment, String, Number}
+
+// A Token is a span of source code of a single kind.
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// stringDelimiter describes a kind of string literal.
+type stringDelimiter struct {
+	open, close string
+	raw         bool // True if backslash escapes are not recognized.
+	multiline   bool // True if the literal may span lines.
+}
+
+// A language describes the lexical structure of a programming language.
+type language struct {
+	keywords      map[string]bool
+	lineComments  []string
+	blockComments [][2]string
+	strings       []stringDelimiter
+
+	// wordComments is true if line comments must begin at the start of a word, as in shell scripts.
+	wordComments bool
+	// keys is true if identifiers that are followed by a colon are keywords, as in YAML mapping keys.
+	keys bool
+	// identifierRunes holds punctuation that may appear within identifiers.
+	identifierRunes string
+}
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var goLanguage = &language{
+	keywords: words(`break case chan const continue default defer else fallthrough for func go goto if import
+		interface map package range return select struct switch type var true false nil iota`),
+	lineComments:  []string{"//"},
+	blockComments: [][2]string{{"/*", "*/"}},
+	strings: []stringDelimiter{
+		{open: "`", close: "`", raw: true, multiline: true},
+		{open: `"`, close: `"`},
+		{open: "'", close: "'"},
+	},
+}
+
+var shellLanguage = &language{
+	keywords: words(`if then else elif fi for while until do done case esac in function select return local export
+		readonly declare unset shift exit break continue time`),
+	lineComments: []string{"#"},
+	strings: []stringDelimiter{
+		{open: "'", close: "'", raw: true, multiline: true},
+		{open: `"`, close: `"`, multiline: true},
+	},
+	wordComments:    true,
+	identifierRunes: "-",
+}
+
+var jsonLanguage = &language{
+	keywords: words(`true false null`),
+	strings:  []stringDelimiter{{open: `"`, close: `"`}},
+}
+
+var yamlLanguage = &language{
+	keywords:     words(`true false yes no on off null ~`),
+	lineComments: []string{"#"},
+	strings: []stringDelimiter{
+		{open: "'", close: "'", raw: true},
+		{open: `"`, close: `"`},
+	},
+	wordComments:    true,
+	keys:            true,
+	identifierRunes: "-.",
+}
+
+var pythonLanguage = &language{
+	keywords: words(`and as assert async await break class continue def del elif else except finally for from global
+		if import in is lambda nonlocal not or pass raise return try while with yield True False None`),
+	lineComments: []string{"#"},
+	strings: []stringDelimiter{
+		{open: `"""`, close: `"""`, multiline: true},
+		{open: "'''", close: "'''", multiline: true},
+		{open: `"`, close: `"`},
+		{open: "'", close: "'"},
+	},
+}
+
+var languages = map[string]*language{
+	"go":     goLanguage,
+	"golang": goLanguage,
+	"sh":     shellLanguage,
+	"shell":  shellLanguage,
+	"bash":   shellLanguage,
+	"zsh":    shellLanguage,
+	"json":   jsonLanguage,
+	"yaml":   yamlLanguage,
+	"yml":    yamlLanguage,
+	"python": pythonLanguage,
+	"py":     pythonLanguage,
+}
+
+// Tokenize splits the given source code into tokens. It returns false if the language is not supported. Language
+// names are case-insensitive; the supported languages are Go ("go"), shell ("sh", "bash", "zsh", or "shell"), JSON
+// ("json"), YAML ("yaml" or "yml"), and Python ("python" or "py").
+func Tokenize(languageName, source string) ([]Token, bool) {
+	lang, ok := languages[strings.ToLower(languageName)]
+	if !ok {
+		return nil, false
+	}
+	return lang.tokenize(source), true
+}
+
+func (l *language) isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(l.identifierRunes, r)
+}
+
+func (l *language) tokenize(source string) []Token {
+	var tokens []Token
+	emit := func(kind Kind, text string) {
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind {
+			tokens[n-1].Text += text
+		} else {
+			tokens = append(tokens, Token{Kind: kind, Text: text})
+		}
+	}
+
+	for i := 0; i < len(source); {
+		rest := source[i:]
+		atWordStart := i == 0 || unicode.IsSpace(rune(source[i-1]))
+
+		if n := l.scanComment(rest, atWordStart); n > 0 {
+			emit(Comment, rest[:n])
+			i += n
+			continue
+		}
+		if n := l.scanString(rest); n > 0 {
+			emit(String, rest[:n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case unicode.IsDigit(r) && (i == 0 || !l.isIdentifierRune(rune(source[i-1]))):
+			n := strings.IndexFunc(rest, func(r rune) bool {
+				return !(r == '.' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+			})
+			if n == -1 {
+				n = len(rest)
+			}
+			emit(Number, rest[:n])
+			i += n
+		case l.isIdentifierRune(r) || l.keywords[string(r)]:
+			n := strings.IndexFunc(rest, func(r rune) bool { return !l.isIdentifierRune(r) })
+			if n == -1 {
+				n = len(rest)
+			}
+			if n == 0 {
+				n = size
+			}
+			word := rest[:n]
+
+			kind := Plain
+			switch {
+			case l.keywords[word]:
+				kind = Keyword
+			case l.keys && isKey(rest[n:]):
+				kind = Keyword
+			}
+			emit(kind, word)
+			i += n
+		default:
+			emit(Plain, rest[:size])
+			i += size
+		}
+	}
+	return tokens
+}
+
+// scanComment returns the length of the comment at the start of s, or 0 if s does not begin with a comment.
+func (l *language) scanComment(s string, atWordStart bool) int {
+	for _, c := range l.blockComments {
+		if strings.HasPrefix(s, c[0]) {
+			if end := strings.Index(s[len(c[0]):], c[1]); end != -1 {
+				return len(c[0]) + end + len(c[1])
+			}
+			return len(s)
+		}
+	}
+	for _, c := range l.lineComments {
+		if strings.HasPrefix(s, c) && (atWordStart || !l.wordComments) {
+			if end := strings.IndexByte(s, '\n'); end != -1 {
+				return end
+			}
+			return len(s)
+		}
+	}
+	return 0
+}
+
+// scanString returns the length of the string literal at the start of s, or 0 if s does not begin with a string
+// literal. Unterminated literals end at the end of the line or the end of s.
+func (l *language) scanString(s string) int {
+	for _, d := range l.strings {
+		if !strings.HasPrefix(s, d.open) {
+			continue
+		}
+		for i := len(d.open); i < len(s); i++ {
+			switch {
+			case strings.HasPrefix(s[i:], d.close):
+				return i + len(d.close)
+			case s[i] == '\\' && !d.raw:
+				i++
+			case s[i] == '\n' && !d.multiline:
+				return i
+			}
+		}
+		return len(s)
+	}
+	return 0
+}
+
+// isKey returns true if s begins with the colon that ends a mapping key.
+func isKey(s string) bool {
+	s = strings.TrimLeft(s, " \t")
+	return strings.HasPrefix(s, ":") && (len(s) == 1 || s[1] == ' ' || s[1] == '\t' || s[1] == '\r' || s[1] == '\n')
+}
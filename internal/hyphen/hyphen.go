@@ -0,0 +1,157 @@
+// Package hyphen finds the points at which words may be hyphenated using Knuth–Liang hyphenation patterns.
+package hyphen
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Patterns holds a set of hyphenation patterns and exceptions for a single language.
+type Patterns struct {
+	// LeftMin and RightMin are the minimum number of letters that must precede and follow a hyphen, respectively.
+	LeftMin, RightMin int
+
+	patterns   map[string][]byte
+	maxLength  int
+	exceptions map[string][]int
+}
+
+// Parse parses hyphenation patterns and exceptions in the format used by TeX. Patterns are letters interleaved with
+// digits, e.g. "hy3ph" or ".ach4". Exceptions are words with their permitted hyphens marked, e.g. "as-so-ciate". The
+// patterns and exceptions may be given in \patterns{...} and \hyphenation{...} groups, or as plain whitespace-separated
+// lists like those in the hyph-utf8 project's .pat.txt and .hyp.txt files, in which case any entry that contains a
+// digit is taken to be a pattern. Comments begin with '%' and run to the end of the line.
+//
+// LeftMin and RightMin default to 2 and 3, as they do in TeX.
+func Parse(text string) (*Patterns, error) {
+	p := &Patterns{
+		LeftMin:    2,
+		RightMin:   3,
+		patterns:   map[string][]byte{},
+		exceptions: map[string][]int{},
+	}
+
+	// Strip comments and separate braces from their neighbors so that groups can be found with strings.Fields.
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.IndexByte(line, '%'); i != -1 {
+			line = line[:i]
+		}
+		b.WriteString(strings.NewReplacer("{", " { ", "}", " } ").Replace(line))
+		b.WriteByte('\n')
+	}
+
+	const (
+		plain = iota
+		patterns
+		exceptions
+	)
+	mode, group := plain, plain
+	for _, token := range strings.Fields(b.String()) {
+		switch {
+		case token == `\patterns`:
+			group = patterns
+		case token == `\hyphenation`:
+			group = exceptions
+		case strings.HasPrefix(token, `\`):
+			return nil, fmt.Errorf("unsupported command '%v'", token)
+		case token == "{":
+			mode = group
+		case token == "}":
+			mode, group = plain, plain
+		case mode == patterns || mode == plain && strings.IndexFunc(token, unicode.IsDigit) != -1:
+			if err := p.addPattern(token); err != nil {
+				return nil, err
+			}
+		default:
+			p.addException(token)
+		}
+	}
+	return p, nil
+}
+
+func (p *Patterns) addPattern(pattern string) error {
+	var letters []rune
+	values := []byte{0}
+	for _, r := range pattern {
+		if r >= '0' && r <= '9' {
+			values[len(values)-1] = byte(r - '0')
+			continue
+		}
+		if r != '.' && !unicode.IsLetter(r) && !unicode.IsMark(r) {
+			return fmt.Errorf("invalid hyphenation pattern '%v'", pattern)
+		}
+		letters, values = append(letters, unicode.ToLower(r)), append(values, 0)
+	}
+	if len(letters) == 0 {
+		return fmt.Errorf("invalid hyphenation pattern '%v'", pattern)
+	}
+
+	p.patterns[string(letters)] = values
+	if len(letters) > p.maxLength {
+		p.maxLength = len(letters)
+	}
+	return nil
+}
+
+func (p *Patterns) addException(exception string) {
+	var word []rune
+	var hyphens []int
+	for _, r := range exception {
+		if r == '-' {
+			hyphens = append(hyphens, len(word))
+			continue
+		}
+		word = append(word, unicode.ToLower(r))
+	}
+	p.exceptions[string(word)] = hyphens
+}
+
+// Hyphenate returns the indices of the runes in the given word that may be preceded by a hyphen. The word should
+// consist solely of letters.
+func (p *Patterns) Hyphenate(word string) []int {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) < p.LeftMin+p.RightMin {
+		return nil
+	}
+
+	var hyphens []int
+	if exception, ok := p.exceptions[string(runes)]; ok {
+		hyphens = exception
+	} else {
+		// Match every pattern against every substring of the word surrounded by word boundaries. Each pattern holds the
+		// values of the positions before, between, and after its letters; the value of each position in the word is
+		// the largest value given to it by any matching pattern. Odd values permit hyphens.
+		dotted := append(append([]rune{'.'}, runes...), '.')
+		values := make([]byte, len(dotted)+1)
+		for i := range dotted {
+			for j := i + 1; j <= len(dotted) && j-i <= p.maxLength; j++ {
+				pattern, ok := p.patterns[string(dotted[i:j])]
+				if !ok {
+					continue
+				}
+				for k, v := range pattern {
+					if v > values[i+k] {
+						values[i+k] = v
+					}
+				}
+			}
+		}
+
+		// The position before runes[i] is the position before dotted[i+1].
+		for i := range runes {
+			if values[i+1]%2 == 1 {
+				hyphens = append(hyphens, i)
+			}
+		}
+	}
+
+	var result []int
+	for _, i := range hyphens {
+		if i >= p.LeftMin && i <= len(runes)-p.RightMin {
+			result = append(result, i)
+		}
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package hyphen
+
+import (
+	"strings"
+	"testing"
+)
+
+// hyphenate returns the given word with a hyphen inserted at each of its hyphenation points.
+func hyphenate(p *Patterns, word string) string {
+	runes := []rune(word)
+
+	var b strings.Builder
+	last := 0
+	for _, i := range p.Hyphenate(word) {
+		b.WriteString(string(runes[last:i]))
+		b.WriteByte('-')
+		last = i
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+func TestEnglish(t *testing.T) {
+	cases := []string{
+		// Words hyphenated by the patterns, including the examples from Appendix H of The TeXbook.
+		"hy-phen-a-tion",
+		"con-cate-na-tion",
+		"su-per-cal-ifrag-ilis-tic-ex-pi-ali-do-cious",
+		"al-go-rithm",
+		"type-set-ting",
+		"dic-tio-nary",
+		"pre-sen-ta-tion",
+
+		// Case does not affect hyphenation.
+		"Mis-sis-sippi",
+		"Hy-phen-a-tion",
+
+		// Words hyphenated by the exceptions.
+		"as-so-ciate",
+		"as-so-ciates",
+		"ta-ble",
+
+		// Hyphens are not permitted within LeftMin letters of the start or RightMin letters of the end of a word. The
+		// exception "acad-e-my" would otherwise leave two letters after the last hyphen.
+		"acad-emy",
+		"project",
+		"ab",
+	}
+	for _, expected := range cases {
+		word := strings.Replace(expected, "-", "", -1)
+		if actual := hyphenate(English, word); actual != expected {
+			t.Errorf("expected '%v', got '%v'", expected, actual)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	const text = `
+% The patterns and exceptions of a tiny language.
+\patterns{
+	1ba .ab2 % a comment
+}
+\hyphenation{ bab-ba-bab }
+a1a
+ab-ab-ab
+`
+	p, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.LeftMin, p.RightMin = 1, 1
+
+	cases := []string{
+		// 1ba permits a hyphen before each "ba", but .ab2 forbids one after a leading "ab".
+		"a-bab-ba",
+		"abba-ba",
+		// a1a permits a hyphen between two a's.
+		"ba-a-ba",
+		// The exceptions replace the patterns.
+		"bab-ba-bab",
+		"ab-ab-ab",
+	}
+	for _, expected := range cases {
+		word := strings.Replace(expected, "-", "", -1)
+		if actual := hyphenate(p, word); actual != expected {
+			t.Errorf("expected '%v', got '%v'", expected, actual)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		`\patterns{ a1b } \lccode`: `unsupported command '\lccode'`,
+		`\patterns{ a-1b }`:        `invalid hyphenation pattern 'a-1b'`,
+		`1 2`:                      `invalid hyphenation pattern '1'`,
+	}
+	for text, expected := range cases {
+		if _, err := Parse(text); err == nil || err.Error() != expected {
+			t.Errorf("%v: expected error '%v', got %v", text, expected, err)
+		}
+	}
+}
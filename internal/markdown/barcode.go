@@ -0,0 +1,284 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/pdf417"
+	"github.com/boombuler/barcode/qr"
+	"github.com/pgavlin/goldmark"
+	"github.com/pgavlin/goldmark/ast"
+	"github.com/pgavlin/goldmark/parser"
+	mdtext "github.com/pgavlin/goldmark/text"
+	"github.com/pgavlin/goldmark/util"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// A BarcodeSymbology identifies a kind of barcode that may be printed using a barcode block.
+type BarcodeSymbology string
+
+const (
+	BarcodeCode128 BarcodeSymbology = "code128" // Code 128, for arbitrary ASCII text.
+	BarcodeEAN13   BarcodeSymbology = "ean13"   // EAN-13, for 12 digits plus a check digit.
+	BarcodeUPCA    BarcodeSymbology = "upca"    // UPC-A, for 11 digits plus a check digit.
+	BarcodeQR      BarcodeSymbology = "qr"      // QR codes, for arbitrary text.
+	BarcodePDF417  BarcodeSymbology = "pdf417"  // PDF417, for arbitrary text.
+)
+
+// KindBarcode is the NodeKind of the Barcode node.
+var KindBarcode = ast.NewNodeKind("Barcode")
+
+// A Barcode is a block that is printed as a barcode. Barcodes are written as fenced code blocks whose info string is
+// "barcode <symbology> [options...]", where symbology is one of "code128", "ean13", "upca", "qr", or "pdf417". The
+// options are:
+//
+// - "text", which prints the barcode's contents beneath it
+// - "full", which scales the barcode to the full width of the paper
+// - "center", which centers the barcode at its default size (the default)
+//
+// For example:
+//
+//	```barcode ean13 text
+//	4006381333931
+//	```
+type Barcode struct {
+	ast.BaseBlock
+
+	Symbology BarcodeSymbology
+	// PrintText is true if the barcode's contents should be printed beneath it.
+	PrintText bool
+	// FullWidth is true if the barcode should be scaled to the full width of the paper.
+	FullWidth bool
+	// Value is the barcode's contents.
+	Value []byte
+
+	// badOption is the first unrecognized option in the barcode's info string, if any.
+	badOption string
+}
+
+// Dump implements Node.Dump.
+func (n *Barcode) Dump(w io.Writer, source []byte, level int) {
+	m := map[string]string{
+		"Symbology": string(n.Symbology),
+		"PrintText": fmt.Sprintf("%v", n.PrintText),
+		"FullWidth": fmt.Sprintf("%v", n.FullWidth),
+		"Value":     fmt.Sprintf("%q", n.Value),
+	}
+	ast.DumpHelper(w, n, source, level, m, nil)
+}
+
+// Kind implements Node.Kind.
+func (n *Barcode) Kind() ast.NodeKind {
+	return KindBarcode
+}
+
+// IsRaw implements Node.IsRaw.
+func (n *Barcode) IsRaw() bool {
+	return true
+}
+
+// barcodeTransformer replaces fenced code blocks whose info strings begin with "barcode" with Barcode nodes.
+type barcodeTransformer struct{}
+
+func (barcodeTransformer) Transform(doc *ast.Document, reader mdtext.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var blocks []*ast.FencedCodeBlock
+	ast.Walk(doc, func(n ast.Node, enter bool) (ast.WalkStatus, error) {
+		if block, ok := n.(*ast.FencedCodeBlock); ok && enter && block.Info != nil {
+			if fields := strings.Fields(string(block.Info.Text(source))); len(fields) > 0 && fields[0] == "barcode" {
+				blocks = append(blocks, block)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, block := range blocks {
+		fields := strings.Fields(string(block.Info.Text(source)))
+
+		node := &Barcode{}
+		if len(fields) > 1 {
+			node.Symbology = BarcodeSymbology(strings.ToLower(fields[1]))
+		}
+		var options []string
+		if len(fields) > 2 {
+			options = fields[2:]
+		}
+		for _, option := range options {
+			switch strings.ToLower(option) {
+			case "text":
+				node.PrintText = true
+			case "full":
+				node.FullWidth = true
+			case "center":
+				node.FullWidth = false
+			default:
+				if node.badOption == "" {
+					node.badOption = option
+				}
+			}
+		}
+
+		var value bytes.Buffer
+		lines := block.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			value.Write(line.Value(source))
+		}
+		node.Value = bytes.TrimRight(value.Bytes(), "\r\n")
+
+		block.Parent().ReplaceChild(block.Parent(), block, node)
+	}
+}
+
+type barcodeExtension struct{}
+
+// Barcodes is an extension that prints fenced code blocks with a "barcode" info string as barcodes.
+var Barcodes goldmark.Extender = barcodeExtension{}
+
+func (barcodeExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(barcodeTransformer{}, 100)))
+}
+
+// checkDigit computes the GS1 check digit for the given digits.
+func checkDigit(digits string) byte {
+	sum := 0
+	for i := range digits {
+		weight := 1
+		if (len(digits)-i)%2 == 1 {
+			weight = 3
+		}
+		sum += int(digits[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// encodeGTIN encodes an EAN-13 or UPC-A value. The value must contain only digits. If the value includes its check
+// digit, the check digit is validated; otherwise, it is computed.
+func encodeGTIN(symbology BarcodeSymbology, value string, length int) (barcode.Barcode, string, error) {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return nil, "", fmt.Errorf("%v barcodes may only contain digits", symbology)
+		}
+	}
+
+	switch len(value) {
+	case length - 1:
+		value += string(checkDigit(value))
+	case length:
+		if expected := checkDigit(value[:length-1]); value[length-1] != expected {
+			return nil, "", fmt.Errorf("invalid check digit in %v barcode '%v': expected %c, got %c", symbology, value,
+				expected, value[length-1])
+		}
+	default:
+		return nil, "", fmt.Errorf("%v barcodes must have %v or %v digits", symbology, length-1, length)
+	}
+
+	// UPC-A codes are EAN-13 codes with a leading zero.
+	encoded := value
+	if len(encoded) == 12 {
+		encoded = "0" + encoded
+	}
+	code, err := ean.Encode(encoded)
+	if err != nil {
+		return nil, "", err
+	}
+	return code, value, nil
+}
+
+// encode encodes the barcode's value. The returned text is the human-readable form of the value, which includes any
+// computed check digit.
+func (n *Barcode) encode() (barcode.Barcode, string, error) {
+	if n.badOption != "" {
+		return nil, "", fmt.Errorf("unknown barcode option '%v'", n.badOption)
+	}
+
+	value := string(n.Value)
+	switch n.Symbology {
+	case BarcodeCode128:
+		value = strings.Replace(value, "\n", "", -1)
+		code, err := code128.Encode(value)
+		return code, value, err
+	case BarcodeEAN13:
+		return encodeGTIN(n.Symbology, value, 13)
+	case BarcodeUPCA:
+		return encodeGTIN(n.Symbology, value, 12)
+	case BarcodeQR:
+		code, err := qr.Encode(value, qr.M, qr.Auto)
+		return code, value, err
+	case BarcodePDF417:
+		code, err := pdf417.Encode(value, 2)
+		return code, value, err
+	case "":
+		return nil, "", fmt.Errorf("missing barcode symbology")
+	default:
+		return nil, "", fmt.Errorf("unknown barcode symbology '%v'", n.Symbology)
+	}
+}
+
+// scaleBarcode scales the given barcode up by an integral factor. Full-width barcodes use the largest factor that fits
+// in the available width; other barcodes use a factor that produces modules of roughly the given size in points, but
+// no larger than fits. One-dimensional barcodes are given the requested height and room for a quiet zone ten modules
+// wide on either side.
+func scaleBarcode(code barcode.Barcode, fullWidth bool, available int, dpi, modulePoints, heightPoints float64) (barcode.Barcode, error) {
+	bounds := code.Bounds()
+	width := bounds.Dx()
+	if bounds.Dy() == 1 {
+		width += 20
+	}
+	if width > available {
+		return nil, fmt.Errorf("barcode is too wide to print")
+	}
+
+	factor := available / width
+	if !fullWidth {
+		if f := int(math.Round(modulePoints / 72.0 * dpi)); f < factor {
+			factor = f
+		}
+		if factor < 1 {
+			factor = 1
+		}
+	}
+
+	if bounds.Dy() == 1 {
+		return barcode.Scale(code, bounds.Dx()*factor, int(math.Ceil(heightPoints/72.0*dpi)))
+	}
+	return barcode.Scale(code, bounds.Dx()*factor, bounds.Dy()*factor)
+}
+
+// captionBarcode returns a bitmap that holds the given barcode with the given text centered beneath it.
+func captionBarcode(code *bitmap.Image, face font.Face, caption string) *bitmap.Image {
+	codeBounds := code.Bounds()
+	metrics := face.Metrics()
+	drawer := font.Drawer{Face: face, Src: image.NewUniform(color.Black)}
+	captionWidth := drawer.MeasureString(caption)
+
+	width := codeBounds.Dx()
+	if captionWidth.Ceil() > width {
+		width = captionWidth.Ceil()
+	}
+	img := bitmap.New(image.Rect(0, 0, width, codeBounds.Dy()+(metrics.Ascent+metrics.Descent).Ceil()))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, codeBounds.Sub(codeBounds.Min).Add(image.Point{(width - codeBounds.Dx()) / 2, 0}), code,
+		codeBounds.Min, draw.Src)
+
+	drawer.Dst = img
+	drawer.Dot = fixed.Point26_6{
+		X: fixed.I(width)/2 - captionWidth/2,
+		Y: fixed.I(codeBounds.Dy()) + metrics.Ascent,
+	}
+	drawer.DrawString(caption)
+	return img
+}
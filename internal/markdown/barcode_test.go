@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/pgavlin/goldmark"
+	"github.com/pgavlin/goldmark/ast"
+	mdtext "github.com/pgavlin/goldmark/text"
+)
+
+// parseBarcode parses the given document and returns its first barcode block, if any.
+func parseBarcode(t *testing.T, source string) *Barcode {
+	md := goldmark.New(goldmark.WithExtensions(Barcodes))
+	doc := md.Parser().Parse(mdtext.NewReader([]byte(source)))
+
+	var result *Barcode
+	ast.Walk(doc, func(n ast.Node, enter bool) (ast.WalkStatus, error) {
+		if b, ok := n.(*Barcode); ok && enter && result == nil {
+			result = b
+		}
+		return ast.WalkContinue, nil
+	})
+	if result == nil {
+		t.Fatalf("no barcode in %q", source)
+	}
+	return result
+}
+
+func TestBarcodeInfo(t *testing.T) {
+	cases := []struct {
+		source string
+		err    string
+	}{
+		{source: "```barcode\n123\n```", err: "missing barcode symbology"},
+		{source: "```barcode   \n123\n```", err: "missing barcode symbology"},
+		{source: "```barcode maxicode\n123\n```", err: "unknown barcode symbology 'maxicode'"},
+		{source: "```barcode qr sideways\n123\n```", err: "unknown barcode option 'sideways'"},
+		{source: "```barcode qr text full\n123\n```"},
+		{source: "```barcode ean13 TEXT\n4006381333931\n```"},
+	}
+	for _, c := range cases {
+		node := parseBarcode(t, c.source)
+		_, _, err := node.encode()
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%q: unexpected error: %v", c.source, err)
+		case c.err != "" && (err == nil || err.Error() != c.err):
+			t.Errorf("%q: expected error %q, got %v", c.source, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package markdown
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	mdtext "github.com/pgavlin/goldmark/text"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+	"github.com/pgavlin/lilprinty/internal/highlight"
+)
+
+// A CodeOverflow describes how code blocks with lines that are wider than the paper are printed.
+type CodeOverflow string
+
+const (
+	// CodeOverflowWrap breaks long lines. Each continuation line begins with a marker.
+	CodeOverflowWrap CodeOverflow = "wrap"
+	// CodeOverflowShrink reduces the point size of the block until its longest line fits, down to a minimum size.
+	// Lines that are still too long are wrapped.
+	CodeOverflowShrink CodeOverflow = "shrink"
+	// CodeOverflowRotate prints the block rotated 90 degrees clockwise, so that its lines run along the paper. Blocks
+	// with too many lines to fit across the paper are wrapped.
+	CodeOverflowRotate CodeOverflow = "rotate"
+	// CodeOverflowClip prints long lines as-is, cutting them off at the edge of the paper.
+	CodeOverflowClip CodeOverflow = "clip"
+)
+
+// DefaultMinCodePointSize is the smallest point size used by CodeOverflowShrink if no other minimum is given.
+const DefaultMinCodePointSize = 5.0
+
+// tabWidth is the number of columns between tab stops in code blocks.
+const tabWidth = 4
+
+// codeText returns the text of a code block with its tabs expanded to spaces.
+func codeText(source []byte, lines *mdtext.Segments) string {
+	var code strings.Builder
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+
+		column := 0
+		for _, c := range string(line.Value(source)) {
+			if c == '\t' {
+				n := tabWidth - column%tabWidth
+				code.WriteString(strings.Repeat(" ", n))
+				column += n
+				continue
+			}
+			code.WriteRune(c)
+			column++
+		}
+	}
+	return code.String()
+}
+
+// codeContents returns the contents of a code block printed at the given point size. If the block's language is
+// supported by the highlight package, its tokens are styled according to the renderer's highlight style.
+func (r *Renderer) codeContents(code, language string, pointSize float64) []content {
+	family := r.monospaceFamily.Size(pointSize)
+
+	tokens, ok := highlight.Tokenize(language, code)
+	if !ok {
+		tokens = []highlight.Token{{Kind: highlight.Plain, Text: code}}
+	}
+
+	var contents []content
+	for _, token := range tokens {
+		style := TextStyle{}
+		if ok {
+			style = r.highlight[token.Kind]
+		}
+
+		var decoration decoration
+		if style.Underline {
+			decoration |= decorationUnderline
+		}
+		if style.Invert {
+			decoration |= decorationInvert
+		}
+		face := family.Face(style.Bold, style.Italic)
+
+		// Code is laid out line by line, so split tokens that span lines.
+		for rest := token.Text; len(rest) > 0; {
+			n := strings.IndexByte(rest, '\n') + 1
+			if n == 0 {
+				n = len(rest)
+			}
+			contents = append(contents, r.withFallbacks(text{face: face, bytes: []byte(rest[:n]), decoration: decoration})...)
+			rest = rest[n:]
+		}
+	}
+	return contents
+}
+
+// measureCode returns the width of the widest line in the given code block contents.
+func measureCode(contents []content) fixed.Int26_6 {
+	var width, lineWidth fixed.Int26_6
+	for _, c := range contents {
+		t, ok := c.(text)
+		if !ok {
+			continue
+		}
+		for _, r := range string(t.bytes) {
+			if r == '\n' {
+				lineWidth = 0
+				continue
+			}
+			if a, ok := t.face.GlyphAdvance(r); ok {
+				lineWidth += a
+			}
+			if lineWidth > width {
+				width = lineWidth
+			}
+		}
+	}
+	return width
+}
+
+// continuationMarker draws the marker printed at the start of a wrapped code line: an arrow that points down and then
+// to the right.
+func continuationMarker(face font.Face) *bitmap.Image {
+	size := (face.Metrics().Ascent * 3 / 4).Ceil()
+	thickness := size / 10
+	if thickness < 1 {
+		thickness = 1
+	}
+
+	img := bitmap.New(image.Rect(0, 0, size, size))
+	black := image.NewUniform(color.Black)
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	// The stem runs down the left side and along the bottom.
+	x0, y1 := size/5, size*3/4
+	draw.Draw(img, image.Rect(x0, 0, x0+thickness, y1+thickness), black, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(x0, y1, size, y1+thickness), black, image.Point{}, draw.Src)
+
+	// The head is a pair of diagonal strokes that meet at the end of the stem.
+	head := size / 4
+	for i := 0; i <= head; i++ {
+		draw.Draw(img, image.Rect(size-1-i, y1-i, size-i+thickness-1, y1-i+thickness), black, image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(size-1-i, y1+i, size-i+thickness-1, y1+i+thickness), black, image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// wrapCode breaks the lines of the given code block contents so that no line is wider than the given width. Each
+// continuation line is indented to match the leading whitespace of the line it continues and begins with a continuation
+// marker. Lines whose leading whitespace is more than half of the width are continued without the indent.
+func wrapCode(contents []content, width fixed.Int26_6, dpi float64) []content {
+	var result []content
+	var lineWidth, indentWidth fixed.Int26_6
+	indent, atStart := 0, true
+	for _, c := range contents {
+		t, ok := c.(text)
+		if !ok {
+			result = append(result, c)
+			continue
+		}
+
+		start := 0
+		for i, r := range string(t.bytes) {
+			if r == '\n' {
+				lineWidth, indentWidth, indent, atStart = 0, 0, 0, true
+				continue
+			}
+
+			a, _ := t.face.GlyphAdvance(r)
+			if atStart {
+				if r == ' ' {
+					indent, indentWidth = indent+1, indentWidth+a
+				} else {
+					atStart = false
+				}
+			}
+
+			if lineWidth+a > width && lineWidth > 0 {
+				// End the current line and begin a continuation line.
+				line := append(append([]byte(nil), t.bytes[start:i]...), '\n')
+				result = append(result, text{face: t.face, bytes: line, decoration: t.decoration})
+				lineWidth = 0
+
+				if indent > 0 && indentWidth <= width/2 {
+					spaces := []byte(strings.Repeat(" ", indent))
+					result = append(result, text{face: t.face, bytes: spaces})
+					lineWidth = indentWidth
+				}
+
+				marker := continuationMarker(t.face)
+				gap := t.face.Metrics().Ascent / 4
+				result = append(result, glyph{bits: marker, rightMargin: fixedToFloat(gap) / dpi * 72.0})
+
+				start, lineWidth = i, lineWidth+fixed.I(marker.Bounds().Dx())+gap
+			}
+			lineWidth += a
+		}
+		if start < len(t.bytes) {
+			result = append(result, text{face: t.face, bytes: t.bytes[start:], decoration: t.decoration})
+		}
+	}
+	return result
+}
+
+// rotateCode prints the given code block contents to a canvas using the line height of the given style and returns the
+// result rotated 90 degrees clockwise.
+func rotateCode(contents []content, width fixed.Int26_6, dpi float64, style BlockStyle) (*bitmap.Image, error) {
+	c := &canvas{width: width.Ceil() + 1, dpi: dpi}
+	style = BlockStyle{LineHeight: style.LineHeight, LineHeightPoints: style.LineHeightPoints}
+	if err := printParagraph(c, contents, true, style, nil); err != nil {
+		return nil, err
+	}
+
+	src := bitmap.New(image.Rect(0, 0, c.width, c.height))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	c.drawTo(src, image.Point{})
+
+	dst := bitmap.New(image.Rect(0, 0, c.height, c.width))
+	for y := 0; y < c.height; y++ {
+		for x := 0; x < c.width; x++ {
+			dst.SetBit(c.height-1-y, x, src.BitAt(x, y))
+		}
+	}
+	return dst, nil
+}
+
+// renderCode prints a code block in the given language, applying the renderer's overflow policy to lines that are
+// wider than the paper.
+func (r *Renderer) renderCode(device bitmap.Device, code, language string) error {
+	dpi := device.DPI()
+	indent := int(math.Ceil(r.indentWidth / 72.0 * dpi))
+	available := fixed.I(device.MaxWidth() - indent)
+
+	pointSize := r.codeBlockStyle.PointSize
+	contents := r.codeContents(code, language, pointSize)
+	width := measureCode(contents)
+
+	if width > available && r.codeOverflow == CodeOverflowShrink {
+		// Monospace text scales linearly with its point size, so shrink the block in proportion to its overflow. Step
+		// down further if rounding leaves it a little too wide.
+		pointSize = math.Max(math.Floor(pointSize*fixedToFloat(available)/fixedToFloat(width)*4)/4, r.minCodePointSize)
+		for {
+			contents = r.codeContents(code, language, pointSize)
+			if width = measureCode(contents); width <= available || pointSize <= r.minCodePointSize {
+				break
+			}
+			pointSize = math.Max(pointSize-0.25, r.minCodePointSize)
+		}
+	}
+
+	switch {
+	case width <= available || r.codeOverflow == CodeOverflowClip:
+		// Print the block as-is.
+	case r.codeOverflow == CodeOverflowRotate:
+		rotated, err := rotateCode(contents, width, dpi, r.codeBlockStyle)
+		if err != nil {
+			return err
+		}
+		if rotated.Bounds().Dx() <= device.MaxWidth()-indent {
+			return r.printCodeBitmap(device, rotated, indent)
+		}
+		contents = wrapCode(contents, available, dpi)
+	default:
+		contents = wrapCode(contents, available, dpi)
+	}
+
+	r.appendContent(contents...)
+	return r.printParagraph(device, r.codeBlockStyle, true)
+}
+
+// printCodeBitmap prints a rendered code block at the given indent, surrounded by the paragraph margins.
+func (r *Renderer) printCodeBitmap(device bitmap.Device, code *bitmap.Image, indent int) error {
+	if err := r.printMargin(device, r.codeBlockStyle.TopMargin); err != nil {
+		return err
+	}
+	if err := r.flushParagraph(device); err != nil {
+		return err
+	}
+
+	bounds := code.Bounds()
+	img := bitmap.New(image.Rect(0, 0, device.MaxWidth(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	r.drawVRules(device, img)
+	draw.Draw(img, bounds.Sub(bounds.Min).Add(image.Point{indent, 0}), code, bounds.Min, draw.Src)
+	if err := device.PrintBitmap(img); err != nil {
+		return err
+	}
+	return r.printMargin(device, r.codeBlockStyle.BottomMargin)
+}
@@ -0,0 +1,164 @@
+package markdown
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/url"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/aztec"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/qr"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// A Symbology identifies a kind of two-dimensional barcode used to encode links.
+type Symbology string
+
+const (
+	SymbologyNone       Symbology = "none"       // Links are not encoded.
+	SymbologyDataMatrix Symbology = "datamatrix" // Links are encoded as Data Matrix symbols.
+	SymbologyQR         Symbology = "qr"         // Links are encoded as QR codes.
+	SymbologyAztec      Symbology = "aztec"      // Links are encoded as Aztec codes.
+)
+
+// LinkCode describes how links are encoded.
+type LinkCode struct {
+	Symbology Symbology
+	// QRLevel is the error correction level used for QR codes.
+	QRLevel qr.ErrorCorrectionLevel
+}
+
+// DefaultLinkCode encodes links as Data Matrix symbols.
+var DefaultLinkCode = LinkCode{Symbology: SymbologyDataMatrix, QRLevel: qr.M}
+
+// ParseLinkCode parses a link code specification of the form "symbology" or "qr:level", where symbology is one of
+// "none", "datamatrix", "qr", or "aztec" and level is one of the QR error correction levels "L", "M", "Q", or "H".
+// QR codes use level M if no level is given.
+func ParseLinkCode(spec string) (LinkCode, error) {
+	name, level := spec, ""
+	if colon := strings.IndexByte(spec, ':'); colon != -1 {
+		name, level = spec[:colon], spec[colon+1:]
+	}
+
+	code := LinkCode{Symbology: Symbology(strings.ToLower(name)), QRLevel: qr.M}
+	switch code.Symbology {
+	case SymbologyNone, SymbologyDataMatrix, SymbologyAztec:
+		if level != "" {
+			return LinkCode{}, fmt.Errorf("link code '%v' does not accept an error correction level", name)
+		}
+	case SymbologyQR:
+		switch strings.ToUpper(level) {
+		case "L":
+			code.QRLevel = qr.L
+		case "", "M":
+			code.QRLevel = qr.M
+		case "Q":
+			code.QRLevel = qr.Q
+		case "H":
+			code.QRLevel = qr.H
+		default:
+			return LinkCode{}, fmt.Errorf("QR error correction level must be L, M, Q, or H")
+		}
+	default:
+		return LinkCode{}, fmt.Errorf("unknown link code '%v'", name)
+	}
+	return code, nil
+}
+
+// linkCodeFragmentPrefix introduces a link code override in a URL fragment, e.g. "https://example.com#code=qr:H".
+const linkCodeFragmentPrefix = "code="
+
+// overrideLinkCode applies any per-link override to the given link code. A link's code may be overridden by a title
+// that is a link code specification, e.g. [text](https://example.com "qr:H"), or by a URL fragment of the form
+// "code=spec", e.g. [text](https://example.com#code=aztec). A fragment override is removed from the returned URL.
+func overrideLinkCode(code LinkCode, destination, title string) (LinkCode, string) {
+	if c, err := ParseLinkCode(title); err == nil {
+		code = c
+	}
+
+	if u, err := url.Parse(destination); err == nil && strings.HasPrefix(u.Fragment, linkCodeFragmentPrefix) {
+		if c, err := ParseLinkCode(u.Fragment[len(linkCodeFragmentPrefix):]); err == nil {
+			code = c
+			u.Fragment = ""
+			destination = u.String()
+		}
+	}
+
+	return code, destination
+}
+
+// encode encodes the given URL using the link code's symbology.
+func (c LinkCode) encode(url string) (barcode.Barcode, error) {
+	var code barcode.Barcode
+	var err error
+	switch c.Symbology {
+	case SymbologyQR:
+		code, err = qr.Encode(url, c.QRLevel, qr.Auto)
+	case SymbologyAztec:
+		code, err = aztec.Encode([]byte(url), aztec.DEFAULT_EC_PERCENT, aztec.DEFAULT_LAYERS)
+	default:
+		code, err = datamatrix.Encode(url)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errLinkTooLarge, err)
+	}
+	return code, nil
+}
+
+// captionLinkCode returns a bitmap that holds the given link code with its URL printed beneath it in the given face.
+// The URL is broken across as many lines as necessary to fit within maxWidth pixels.
+func captionLinkCode(code *bitmap.Image, face font.Face, url string, maxWidth int) *bitmap.Image {
+	// Break the URL into lines.
+	var lines [][]rune
+	var line []rune
+	var lineWidth, width fixed.Int26_6
+	for _, c := range url {
+		advance, ok := face.GlyphAdvance(c)
+		if !ok {
+			continue
+		}
+		if len(line) > 0 && (lineWidth+advance).Ceil() > maxWidth {
+			lines, line, lineWidth = append(lines, line), nil, 0
+		}
+		line, lineWidth = append(line, c), lineWidth+advance
+		if lineWidth > width {
+			width = lineWidth
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+
+	codeBounds := code.Bounds()
+	metrics := face.Metrics()
+	lineHeight := (metrics.Ascent + metrics.Descent).Ceil()
+
+	imgWidth := width.Ceil()
+	if codeBounds.Dx() > imgWidth {
+		imgWidth = codeBounds.Dx()
+	}
+	img := bitmap.New(image.Rect(0, 0, imgWidth, codeBounds.Dy()+len(lines)*lineHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	// Center the code and the lines beneath it.
+	codeLeft := (imgWidth - codeBounds.Dx()) / 2
+	draw.Draw(img, codeBounds.Sub(codeBounds.Min).Add(image.Point{codeLeft, 0}), code, codeBounds.Min, draw.Src)
+
+	drawer := font.Drawer{Dst: img, Src: image.NewUniform(color.Black), Face: face}
+	for i, line := range lines {
+		lineWidth := drawer.MeasureString(string(line))
+		drawer.Dot = fixed.Point26_6{
+			X: fixed.I(imgWidth)/2 - lineWidth/2,
+			Y: fixed.I(codeBounds.Dy()+i*lineHeight) + metrics.Ascent,
+		}
+		drawer.DrawString(string(line))
+	}
+	return img
+}
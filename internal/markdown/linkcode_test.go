@@ -0,0 +1,147 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/pgavlin/lilprinty/internal/font"
+)
+
+func TestParseLinkCode(t *testing.T) {
+	cases := []struct {
+		spec     string
+		expected LinkCode
+		err      string
+	}{
+		{spec: "none", expected: LinkCode{Symbology: SymbologyNone, QRLevel: qr.M}},
+		{spec: "datamatrix", expected: LinkCode{Symbology: SymbologyDataMatrix, QRLevel: qr.M}},
+		{spec: "DataMatrix", expected: LinkCode{Symbology: SymbologyDataMatrix, QRLevel: qr.M}},
+		{spec: "aztec", expected: LinkCode{Symbology: SymbologyAztec, QRLevel: qr.M}},
+		{spec: "qr", expected: LinkCode{Symbology: SymbologyQR, QRLevel: qr.M}},
+		{spec: "qr:L", expected: LinkCode{Symbology: SymbologyQR, QRLevel: qr.L}},
+		{spec: "qr:M", expected: LinkCode{Symbology: SymbologyQR, QRLevel: qr.M}},
+		{spec: "qr:q", expected: LinkCode{Symbology: SymbologyQR, QRLevel: qr.Q}},
+		{spec: "QR:H", expected: LinkCode{Symbology: SymbologyQR, QRLevel: qr.H}},
+		{spec: "qr:", expected: LinkCode{Symbology: SymbologyQR, QRLevel: qr.M}},
+
+		{spec: "", err: "unknown link code ''"},
+		{spec: "pdf417", err: "unknown link code 'pdf417'"},
+		{spec: "qr:X", err: "QR error correction level must be L, M, Q, or H"},
+		{spec: "aztec:H", err: "link code 'aztec' does not accept an error correction level"},
+		{spec: "datamatrix:L", err: "link code 'datamatrix' does not accept an error correction level"},
+	}
+	for _, c := range cases {
+		actual, err := ParseLinkCode(c.spec)
+		switch {
+		case c.err != "" && (err == nil || err.Error() != c.err):
+			t.Errorf("%q: expected error %q, got %v", c.spec, c.err, err)
+		case c.err == "" && err != nil:
+			t.Errorf("%q: unexpected error: %v", c.spec, err)
+		case actual != c.expected:
+			t.Errorf("%q: expected %+v, got %+v", c.spec, c.expected, actual)
+		}
+	}
+}
+
+func TestOverrideLinkCode(t *testing.T) {
+	cases := []struct {
+		name, destination, title string
+		expectedCode             LinkCode
+		expectedURL              string
+	}{
+		{name: "no override", destination: "https://example.com/a", expectedCode: DefaultLinkCode,
+			expectedURL: "https://example.com/a"},
+		{name: "title", destination: "https://example.com/a", title: "qr:H",
+			expectedCode: LinkCode{Symbology: SymbologyQR, QRLevel: qr.H}, expectedURL: "https://example.com/a"},
+		{name: "descriptive title", destination: "https://example.com/a", title: "An example",
+			expectedCode: DefaultLinkCode, expectedURL: "https://example.com/a"},
+		{name: "title none", destination: "https://example.com/a", title: "none",
+			expectedCode: LinkCode{Symbology: SymbologyNone, QRLevel: qr.M}, expectedURL: "https://example.com/a"},
+		{name: "fragment", destination: "https://example.com/a?b=c#code=aztec",
+			expectedCode: LinkCode{Symbology: SymbologyAztec, QRLevel: qr.M}, expectedURL: "https://example.com/a?b=c"},
+		{name: "fragment with level", destination: "https://example.com/#code=qr:L",
+			expectedCode: LinkCode{Symbology: SymbologyQR, QRLevel: qr.L}, expectedURL: "https://example.com/"},
+		{name: "fragment overrides title", destination: "https://example.com/a#code=aztec", title: "qr",
+			expectedCode: LinkCode{Symbology: SymbologyAztec, QRLevel: qr.M}, expectedURL: "https://example.com/a"},
+		{name: "other fragment", destination: "https://example.com/a#section",
+			expectedCode: DefaultLinkCode, expectedURL: "https://example.com/a#section"},
+		{name: "invalid fragment", destination: "https://example.com/a#code=pdf417",
+			expectedCode: DefaultLinkCode, expectedURL: "https://example.com/a#code=pdf417"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			code, url := overrideLinkCode(DefaultLinkCode, c.destination, c.title)
+			if code != c.expectedCode {
+				t.Errorf("expected code %+v, got %+v", c.expectedCode, code)
+			}
+			if url != c.expectedURL {
+				t.Errorf("expected URL %q, got %q", c.expectedURL, url)
+			}
+		})
+	}
+}
+
+// recordingShortener records the URLs that it is asked to shorten and returns them unchanged.
+type recordingShortener struct {
+	urls []string
+}
+
+func (s *recordingShortener) Shorten(url string) (string, error) {
+	s.urls = append(s.urls, url)
+	return url, nil
+}
+
+func TestLinkCodeURLs(t *testing.T) {
+	options := truetype.Options{DPI: 203.2, SubPixelsX: 1}
+	proportional, err := font.ParseFamily(goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	monospace, err := font.ParseFamily(gomono.TTF, gomonobold.TTF, gomonoitalic.TTF, gomonobolditalic.TTF, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const source = `[fragment](https://example.com/a?b=c#code=qr:H)
+[title](https://example.com/b "aztec")
+[none](https://example.com/c#code=none)
+[section](https://example.com/d#section)
+<https://example.com/e#code=qr>
+`
+
+	// Each link's URL is shortened, encoded, and printed as its caption in that order, so the URLs given to the
+	// shortener are the URLs that are encoded and printed. Link code overrides are removed from them, and links whose
+	// codes are overridden to none are not encoded at all.
+	shortener := &recordingShortener{}
+	style := Style{
+		ProportionalFamily: proportional,
+		MonospaceFamily:    monospace,
+		ParagraphStyle:     BlockStyle{PointSize: 8},
+		Shortener:          shortener,
+		PrintLinkURLs:      true,
+	}
+	if err := Render(&canvas{width: 384, dpi: options.DPI}, []byte(source), style); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"https://example.com/a?b=c",
+		"https://example.com/b",
+		"https://example.com/d#section",
+		"https://example.com/e",
+	}
+	if !reflect.DeepEqual(shortener.urls, expected) {
+		t.Fatalf("expected URLs %q, got %q", expected, shortener.urls)
+	}
+}
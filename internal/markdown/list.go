@@ -0,0 +1,86 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+// formatListMarker formats the marker for the nth item of an ordered list. The first occurrence of '1', 'a', 'A', 'i',
+// or 'I' in the format is replaced with n as a decimal number, a lower- or uppercase letter, or a lower- or uppercase
+// Roman numeral, respectively. For example, "a)" formats the third item as "c)" and "(i)" formats it as "(iii)". Items
+// that cannot be numbered in the format's style, such as those numbered zero, are numbered in decimal. Formats without
+// a counter are used as-is.
+func formatListMarker(format string, n int) string {
+	i := strings.IndexAny(format, "1aAiI")
+	if i == -1 {
+		return format
+	}
+
+	var counter string
+	switch format[i] {
+	case 'a', 'A':
+		counter = alphabeticNumber(n)
+	case 'i', 'I':
+		counter = romanNumber(n)
+	}
+	if counter == "" {
+		counter = strconv.Itoa(n)
+	} else if format[i] == 'A' || format[i] == 'I' {
+		counter = strings.ToUpper(counter)
+	}
+	return format[:i] + counter + format[i+1:]
+}
+
+// alphabeticNumber returns n in bijective base 26 using the letters a through z, so that 27 is "aa". It returns the
+// empty string if n is not positive.
+func alphabeticNumber(n int) string {
+	var letters []byte
+	for ; n > 0; n = (n - 1) / 26 {
+		letters = append([]byte{byte('a' + (n-1)%26)}, letters...)
+	}
+	return string(letters)
+}
+
+// romanNumber returns n as a lowercase Roman numeral. It returns the empty string if n is not between 1 and 3999.
+func romanNumber(n int) string {
+	if n <= 0 || n >= 4000 {
+		return ""
+	}
+
+	numerals := []struct {
+		value   int
+		numeral string
+	}{
+		{1000, "m"}, {900, "cm"}, {500, "d"}, {400, "cd"}, {100, "c"}, {90, "xc"},
+		{50, "l"}, {40, "xl"}, {10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"},
+	}
+
+	var b strings.Builder
+	for _, numeral := range numerals {
+		for ; n >= numeral.value; n -= numeral.value {
+			b.WriteString(numeral.numeral)
+		}
+	}
+	return b.String()
+}
+
+// listLevel returns the nesting level of the innermost list on the stack among lists of the same kind, starting at
+// zero. Nested bullet lists and nested ordered lists cycle through their markers independently.
+func (r *Renderer) listLevel() int {
+	ordered, level := r.listStack[len(r.listStack)-1].node.IsOrdered(), -1
+	for _, state := range r.listStack {
+		if state.node.IsOrdered() == ordered {
+			level++
+		}
+	}
+	return level
+}
+
+// listMarker returns the marker for the given item of the innermost list on the stack.
+func (r *Renderer) listMarker(index int) string {
+	level := r.listLevel()
+	if r.listStack[len(r.listStack)-1].node.IsOrdered() {
+		return formatListMarker(r.listStyle.OrderedFormats[level%len(r.listStyle.OrderedFormats)], index)
+	}
+	return r.listStyle.Bullets[level%len(r.listStyle.Bullets)]
+}
@@ -2,13 +2,13 @@ package markdown
 
 import (
 	"github.com/pgavlin/goldmark"
+	"github.com/pgavlin/goldmark/extension"
 	mdtext "github.com/pgavlin/goldmark/text"
 	"github.com/pgavlin/lilprinty/internal/bitmap"
-	"github.com/pgavlin/lilprinty/internal/font"
 )
 
-func Render(device bitmap.Device, bytes []byte, proportionalFamily, monospaceFamily *font.Family, headingStyles []BlockStyle, paragraphStyle BlockStyle) error {
-	parser := goldmark.DefaultParser()
-	renderer := NewRenderer(proportionalFamily, monospaceFamily, headingStyles, paragraphStyle)
+func Render(device bitmap.Device, bytes []byte, style Style) error {
+	parser := goldmark.New(goldmark.WithExtensions(extension.GFM, Barcodes)).Parser()
+	renderer := NewRenderer(style)
 	return renderer.Render(device, bytes, parser.Parse(mdtext.NewReader(bytes)))
 }
@@ -13,6 +13,7 @@ import (
 	"golang.org/x/image/math/fixed"
 
 	"github.com/pgavlin/lilprinty/internal/bitmap"
+	"github.com/pgavlin/lilprinty/internal/hyphen"
 )
 
 // 1pt = 1/72nd of an inch
@@ -21,9 +22,19 @@ type content interface {
 	isContent()
 }
 
+// A decoration is a set of lines drawn over text.
+type decoration int
+
+const (
+	decorationStrikethrough decoration = 1 << iota // A line is drawn through the text.
+	decorationUnderline                            // A line is drawn beneath the text.
+	decorationInvert                               // The text is drawn in white on a black background.
+)
+
 type text struct {
-	face  font.Face
-	bytes []byte
+	face       font.Face
+	bytes      []byte
+	decoration decoration
 }
 
 func (text) isContent() {}
@@ -40,7 +51,7 @@ type linebreak struct{}
 func (linebreak) isContent() {}
 
 type indent struct {
-	vrules []float64
+	vrules []vrule
 	points float64
 }
 
@@ -51,8 +62,10 @@ type segment interface {
 }
 
 type textSegment struct {
-	face  font.Face
-	runes []rune
+	face       font.Face
+	runes      []rune
+	decoration decoration
+	tracking   fixed.Int26_6 // The extra space that follows each character.
 }
 
 func (textSegment) isSegment() {}
@@ -65,7 +78,7 @@ type glyphSegment struct {
 func (glyphSegment) isSegment() {}
 
 type indentSegment struct {
-	vrules []fixed.Int26_6
+	vrules []vrule
 	width  fixed.Int26_6
 }
 
@@ -73,6 +86,68 @@ func (indentSegment) isSegment() {}
 
 type line struct {
 	segments []segment
+	wrapped  bool // True if the line was ended because the next word did not fit.
+}
+
+// An Alignment describes how the lines of a paragraph are aligned within the space to the right of their indent.
+type Alignment int
+
+const (
+	AlignLeft    Alignment = iota // Lines are aligned with the left edge.
+	AlignCenter                   // Lines are centered.
+	AlignRight                    // Lines are aligned with the right edge.
+	AlignJustify                  // Wrapped lines are stretched to fill the space. Other lines are aligned left.
+)
+
+// contentStart returns the index of the first segment of the given line's contents, which follow its last indent.
+// Segments that precede the last indent, such as list markers, hang in the indent.
+func contentStart(l line) int {
+	start := 0
+	for i, s := range l.segments {
+		if _, ok := s.(indentSegment); ok {
+			start = i + 1
+		}
+	}
+	return start
+}
+
+// measureLine returns the width of the given line's contents, excluding its indent, any segments that hang in the
+// indent, and any trailing spaces.
+func measureLine(l line) fixed.Int26_6 {
+	segments := l.segments[contentStart(l):]
+	if len(segments) == 0 {
+		return 0
+	}
+
+	_, width := measureWord(line{}, segments)
+	if s, ok := segments[len(segments)-1].(textSegment); ok {
+		for i := len(s.runes) - 1; i >= 0 && s.runes[i] == ' '; i-- {
+			if a, ok := s.face.GlyphAdvance(' '); ok {
+				width -= a + s.tracking
+			}
+		}
+	}
+	return width
+}
+
+// countSpaces returns the number of spaces between the words of the given line, excluding any trailing spaces.
+func countSpaces(l line) int {
+	spaces, trailing := 0, 0
+	for _, s := range l.segments {
+		switch s := s.(type) {
+		case textSegment:
+			for _, c := range s.runes {
+				if c == ' ' {
+					trailing++
+				} else {
+					spaces, trailing = spaces+trailing, 0
+				}
+			}
+		case glyphSegment:
+			spaces, trailing = spaces+trailing, 0
+		}
+	}
+	return spaces
 }
 
 func measureWord(l line, word []segment) (fixed.Int26_6, fixed.Int26_6) {
@@ -102,7 +177,7 @@ func measureWord(l line, word []segment) (fixed.Int26_6, fixed.Int26_6) {
 				}
 				a, ok := s.face.GlyphAdvance(c)
 				if ok {
-					wordWidth += a
+					wordWidth += a + s.tracking
 					prevC, prevMargin = c, 0
 				}
 			}
@@ -121,8 +196,178 @@ func measureWord(l line, word []segment) (fixed.Int26_6, fixed.Int26_6) {
 	return firstKern, wordWidth
 }
 
-func layoutParagraph(outputWidth fixed.Int26_6, outputDPI float64, contents []content, raw bool) []line {
+// trimWord returns the given word without its trailing spaces.
+func trimWord(word []segment) []segment {
+	if len(word) == 0 {
+		return word
+	}
+	s, ok := word[len(word)-1].(textSegment)
+	if !ok {
+		return word
+	}
+
+	n := len(s.runes)
+	for n > 0 && s.runes[n-1] == ' ' {
+		n--
+	}
+	s.runes = s.runes[:n]
+	return append(append([]segment(nil), word[:len(word)-1]...), s)
+}
+
+// A wordBreak is a position within a word at which it may be broken.
+type wordBreak struct {
+	segment, rune int  // The break precedes word[segment].runes[rune].
+	hyphen        bool // True if a hyphen is drawn at the end of the first part.
+	preferred     bool // True if the break follows a hyphen or URL punctuation or is permitted by the patterns.
+}
+
+// isURLBreak returns true if a word may be broken after the given rune without drawing a hyphen. These are the
+// characters that separate the parts of a URL.
+func isURLBreak(r rune) bool {
+	switch r {
+	case '/', '.', '?', '&', '=':
+		return true
+	default:
+		return false
+	}
+}
+
+// wordBreaks returns each position at which the given word may be broken. Words may be broken after an existing hyphen
+// or URL punctuation, at the points permitted by the hyphenation patterns, or, failing that, between any two characters
+// that leave at least the patterns' RightMin characters after the break.
+func wordBreaks(word []segment, patterns *hyphen.Patterns) []wordBreak {
+	// Flatten the word's text. Glyphs are represented by the object replacement character so that they split words.
+	var runes []rune
+	var positions [][2]int
+	for i, s := range word {
+		switch s := s.(type) {
+		case textSegment:
+			for j, r := range s.runes {
+				runes, positions = append(runes, r), append(positions, [2]int{i, j})
+			}
+		case glyphSegment:
+			runes, positions = append(runes, '\uFFFC'), append(positions, [2]int{i, -1})
+		}
+	}
+
+	// Words with URL punctuation inside them are likely to be URLs or paths, where a hyphen would read as part of the
+	// text, so they are not hyphenated.
+	isURL := false
+	for i := 1; i < len(runes); i++ {
+		if isURLBreak(runes[i-1]) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+			isURL = true
+			break
+		}
+	}
+
+	// Find the hyphenation points of each run of letters.
+	hyphenated := map[int]bool{}
+	for start := 0; !isURL && start < len(runes); {
+		if !unicode.IsLetter(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && unicode.IsLetter(runes[end]) {
+			end++
+		}
+		for _, i := range patterns.Hyphenate(string(runes[start:end])) {
+			hyphenated[start+i] = true
+		}
+		start = end
+	}
+
+	// The word may end with the space that separates it from the next word.
+	end := len(runes)
+	for end > 0 && runes[end-1] == ' ' {
+		end--
+	}
+
+	var breaks []wordBreak
+	for i := 1; i < len(runes); i++ {
+		prev, next := runes[i-1], runes[i]
+		if positions[i][1] == -1 || positions[i-1][1] == -1 || prev == ' ' || next == ' ' {
+			continue
+		}
+
+		// Runs of URL punctuation, such as the slashes in "https://", are kept together. Words are not broken before
+		// a hyphen or URL punctuation, as they may be broken after it instead.
+		afterHyphen := prev == '-' || prev == '\u2010'
+		afterURLBreak := isURLBreak(prev) && !isURLBreak(next)
+		preferred := afterHyphen || afterURLBreak || hyphenated[i]
+		if !preferred && (end-i < patterns.RightMin || next == '-' || next == '\u2010' || isURLBreak(next)) {
+			continue
+		}
+
+		breaks = append(breaks, wordBreak{
+			segment:   positions[i][0],
+			rune:      positions[i][1],
+			hyphen:    !afterHyphen && !afterURLBreak,
+			preferred: preferred,
+		})
+	}
+	return breaks
+}
+
+// splitWord splits the given word at the given break. If the break calls for a hyphen, one is appended to the first
+// part in the face of the character that precedes it.
+func splitWord(word []segment, b wordBreak) ([]segment, []segment) {
+	head := append([]segment(nil), word[:b.segment]...)
+	tail := append([]segment(nil), word[b.segment:]...)
+
+	s := word[b.segment].(textSegment)
+	if b.rune > 0 {
+		before := s
+		before.runes = s.runes[:b.rune]
+		head = append(head, before)
+		tail[0] = textSegment{face: s.face, runes: s.runes[b.rune:], decoration: s.decoration, tracking: s.tracking}
+	}
+
+	if b.hyphen {
+		prev := head[len(head)-1].(textSegment)
+		hyphen := prev
+		hyphen.runes = []rune{'-'}
+		head = append(head, hyphen)
+	}
+	return head, tail
+}
+
+// breakWord returns the longest first part of the given word that fits in the available space on the given line,
+// along with the rest of the word. Preferred breaks are used if they fill at least half of the available space.
+// Otherwise, the word is broken between characters. If no part of the word fits, breakWord returns nil, unless force
+// is true, in which case the shortest first part is returned.
+func breakWord(l line, word []segment, available fixed.Int26_6, patterns *hyphen.Patterns, force bool) ([]segment, []segment) {
+	var best, bestTail, preferred, preferredTail []segment
+	var preferredWidth fixed.Int26_6
+	for _, b := range wordBreaks(word, patterns) {
+		head, tail := splitWord(word, b)
+		_, width := measureWord(l, head)
+		if width >= available {
+			if best == nil && force {
+				return head, tail
+			}
+			break
+		}
+
+		best, bestTail = head, tail
+		if b.preferred {
+			preferred, preferredTail, preferredWidth = head, tail, width
+		}
+	}
+
+	if preferred != nil && preferredWidth >= available/2 {
+		return preferred, preferredTail
+	}
+	return best, bestTail
+}
+
+// layoutParagraph breaks the given contents into lines that fit within the given width. Each character of text is
+// followed by the given tracking in points. If patterns is not nil, words that are too wide to fit on a line by
+// themselves are hyphenated using the given patterns or broken between characters. Otherwise, such words overflow the
+// line.
+func layoutParagraph(outputWidth fixed.Int26_6, outputDPI float64, contents []content, raw bool, tracking float64, patterns *hyphen.Patterns) []line {
 	var lines []line
+	trackingWidth := floatToFixed(tracking / 72.0 * outputDPI)
 
 	var l line
 	var word []segment
@@ -135,15 +380,39 @@ func layoutParagraph(outputWidth fixed.Int26_6, outputDPI float64, contents []co
 
 		// Measure the word.
 		firstKern, wordWidth := measureWord(l, word)
+		_, trimmedWidth := measureWord(line{}, trimWord(word))
 
 		switch {
 		case lineWidth+wordWidth < outputWidth:
 			// The word fits on the current line.
 			l.segments, lineWidth = append(l.segments, word...), lineWidth+wordWidth
-		// case wordWidth-firstKern > outputWidth:
-		// TODO: The word doesn't fit on a line at all. Hyphenate it.
+		case patterns != nil && indentWidth+trimmedWidth >= outputWidth:
+			// The word doesn't fit on a line at all. Break it into parts that fill the current line and as many new lines
+			// as necessary.
+			for {
+				_, wordWidth := measureWord(l, word)
+				if lineWidth+wordWidth < outputWidth {
+					l.segments, lineWidth = append(l.segments, word...), lineWidth+wordWidth
+					break
+				}
+
+				fresh := lineWidth <= indentWidth
+				head, tail := breakWord(l, word, outputWidth-lineWidth, patterns, fresh)
+				if head == nil && fresh {
+					// The word can't be broken at all, so let it overflow.
+					l.segments, lineWidth = append(l.segments, word...), lineWidth+wordWidth
+					break
+				}
+				if head != nil {
+					l.segments, word = append(l.segments, head...), tail
+				}
+				l.wrapped = true
+				lines = append(lines, l)
+				l, lineWidth = line{}, indentWidth
+			}
 		default:
 			// Start a new line with the current word.
+			l.wrapped = true
 			lines = append(lines, l)
 			l, lineWidth = line{segments: word}, indentWidth+wordWidth-firstKern
 		}
@@ -161,7 +430,8 @@ func layoutParagraph(outputWidth fixed.Int26_6, outputDPI float64, contents []co
 
 				if raw {
 					if r == '\n' {
-						word = append(word, textSegment{face: t.face, runes: runes})
+						word = append(word, textSegment{face: t.face, runes: runes, decoration: t.decoration, tracking: trackingWidth})
+						runes = nil
 						l.segments = append(l.segments, word...)
 						word = nil
 
@@ -170,7 +440,7 @@ func layoutParagraph(outputWidth fixed.Int26_6, outputDPI float64, contents []co
 					} else {
 						runes = append(runes, r)
 						if len(b) == 0 {
-							word = append(word, textSegment{face: t.face, runes: runes})
+							word = append(word, textSegment{face: t.face, runes: runes, decoration: t.decoration, tracking: trackingWidth})
 						}
 					}
 				} else {
@@ -184,8 +454,10 @@ func layoutParagraph(outputWidth fixed.Int26_6, outputDPI float64, contents []co
 					if isSpace || len(b) == 0 {
 						// Add a segment to the word.
 						word = append(word, textSegment{
-							face:  t.face,
-							runes: runes,
+							face:       t.face,
+							runes:      runes,
+							decoration: t.decoration,
+							tracking:   trackingWidth,
 						})
 						runes = nil
 					}
@@ -205,19 +477,15 @@ func layoutParagraph(outputWidth fixed.Int26_6, outputDPI float64, contents []co
 				rightMargin: rightMargin,
 			})
 		case linebreak:
+			appendWord()
+
 			lines = append(lines, l)
 			l, lineWidth = line{}, indentWidth
 		case indent:
 			appendWord()
 
 			indentWidth = fixed.I(int(math.Ceil(t.points / 72.0 * outputDPI)))
-
-			vrules := make([]fixed.Int26_6, len(t.vrules))
-			for i, vr := range t.vrules {
-				vrules[i] = fixed.I(int(math.Ceil(vr / 72.0 * outputDPI)))
-			}
-
-			l.segments = append(l.segments, indentSegment{vrules: vrules, width: indentWidth})
+			l.segments = append(l.segments, indentSegment{vrules: t.vrules, width: indentWidth})
 			lineWidth = indentWidth
 		}
 	}
@@ -230,32 +498,58 @@ func layoutParagraph(outputWidth fixed.Int26_6, outputDPI float64, contents []co
 	return lines
 }
 
-func printParagraph(output bitmap.Device, contents []content, raw bool) error {
+// printParagraph prints the given contents using the alignment, line height, and tracking of the given style. Words
+// that are too wide to fit on a line are hyphenated using the given patterns, if any.
+func printParagraph(output bitmap.Device, contents []content, raw bool, style BlockStyle, patterns *hyphen.Patterns) error {
 	outputWidth := fixed.I(output.MaxWidth())
+	align := style.Align
 
 	// Layout the paragraph.
-	lines := layoutParagraph(outputWidth, output.DPI(), contents, raw)
+	lines := layoutParagraph(outputWidth, output.DPI(), contents, raw, style.Tracking, patterns)
 
 	// Render each line to a bitmap.
 	var indentWidth fixed.Int26_6
-	var vrules []fixed.Int26_6
+	var vrules []vrule
 	for _, l := range lines {
-		// Calculate the line height.
-		var lineHeight fixed.Int26_6
+		// Calculate the line's natural height, then apply the style's line height. The difference between the two is
+		// split evenly above and below the line's contents. Text in every face shares a baseline, so the natural height
+		// of the text is the greatest ascent plus the greatest descent, which may come from different faces.
+		var ascent, descent, naturalHeight fixed.Int26_6
+		hasGlyphs := false
 		for _, s := range l.segments {
 			switch s := s.(type) {
 			case textSegment:
 				metrics := s.face.Metrics()
-				if metrics.Ascent+metrics.Descent > lineHeight {
-					lineHeight = metrics.Ascent + metrics.Descent
+				if metrics.Ascent > ascent {
+					ascent = metrics.Ascent
+				}
+				if metrics.Descent > descent {
+					descent = metrics.Descent
+				}
+				if ascent+descent > naturalHeight {
+					naturalHeight = ascent + descent
 				}
 			case glyphSegment:
 				height := fixed.I(s.bits.Bounds().Dy())
-				if height > lineHeight {
-					lineHeight = height
+				if height > naturalHeight {
+					naturalHeight = height
 				}
+				hasGlyphs = true
 			}
 		}
+		lineHeight := naturalHeight
+		switch {
+		case style.LineHeightPoints != 0:
+			lineHeight = floatToFixed(style.LineHeightPoints / 72.0 * output.DPI())
+		case style.LineHeight != 0:
+			lineHeight = floatToFixed(fixedToFloat(naturalHeight) * style.LineHeight)
+		}
+		// Images, checkboxes, and link codes must not be cropped, so the style's line height is only a minimum for
+		// lines that contain them.
+		if hasGlyphs && lineHeight < naturalHeight {
+			lineHeight = naturalHeight
+		}
+		leading := (lineHeight - naturalHeight) / 2
 
 		// Create an image for the line.
 		img := bitmap.NewThreshold(image.Rect(0, 0, output.MaxWidth(), lineHeight.Ceil()), 140)
@@ -263,21 +557,51 @@ func printParagraph(output bitmap.Device, contents []content, raw bool) error {
 		src := image.NewUniform(color.Black)
 		dot := fixed.P(indentWidth.Ceil(), 0)
 
-		for _, vr := range vrules {
-			upperLeft := image.Point{vr.Ceil(), dot.Y.Ceil()}
-			vr := image.Rectangle{upperLeft, upperLeft.Add(image.Point{1, lineHeight.Ceil()})}
-			draw.Draw(img, vr, src, image.Point{}, draw.Src)
+		drawVRules(img, vrules, output.DPI(), outputY(output))
+
+		// Measure the line if it is not left-aligned. The offset is applied after any indent. Justified lines are
+		// stretched by distributing the slack across their spaces.
+		var offset, slack fixed.Int26_6
+		first, spaces, space := contentStart(l), 0, 0
+		if align != AlignLeft {
+			lineIndent := indentWidth
+			if first > 0 {
+				lineIndent = l.segments[first-1].(indentSegment).width
+			}
+			offset = outputWidth - lineIndent - measureLine(l)
+			switch align {
+			case AlignCenter:
+				offset /= 2
+			case AlignJustify:
+				if spaces = countSpaces(line{segments: l.segments[first:]}); l.wrapped && spaces > 0 {
+					slack = offset
+				}
+				offset = 0
+			}
 		}
 
 		// Render the line into the image.
 		prevC, prevMargin := rune(-1), fixed.I(0)
-		for _, s := range l.segments {
+		for i, s := range l.segments {
+			if i >= first {
+				dot.X, offset = dot.X+offset, 0
+			}
+
 			switch s := s.(type) {
 			case textSegment:
 				metrics := s.face.Metrics()
-				dot.Y = lineHeight - metrics.Descent
+				dot.Y = leading + naturalHeight - descent
 
+				// Inverted text is drawn in white over a black background that spans the face's ascent and descent.
+				glyphSrc := src
+				if s.decoration&decorationInvert != 0 {
+					glyphSrc = image.NewUniform(color.White)
+				}
+
+				// Decorations extend through spaces between words, but not past the end of the line.
+				start, end := fixed.I(-1), dot.X
 				for _, c := range s.runes {
+					x := dot.X
 					if prevC >= 0 {
 						dot.X += s.face.Kern(prevC, c)
 					} else if prevMargin != 0 {
@@ -287,9 +611,41 @@ func printParagraph(output bitmap.Device, contents []content, raw bool) error {
 					if !ok {
 						continue
 					}
-					draw.DrawMask(img, dr, src, image.Point{}, mask, maskp, draw.Over)
+					if start < 0 {
+						start = dot.X
+					}
+					advance += s.tracking
+					if c == ' ' && i >= first && space < spaces {
+						// Each space receives its share of the slack, rounded so that the shares sum to the slack.
+						n := fixed.Int26_6(spaces)
+						advance += slack*fixed.Int26_6(space+1)/n - slack*fixed.Int26_6(space)/n
+						space++
+					}
+					if s.decoration&decorationInvert != 0 {
+						background := image.Rect(x.Round(), (dot.Y - metrics.Ascent).Round(), (dot.X + advance).Round(),
+							(dot.Y + metrics.Descent).Round())
+						draw.Draw(img, background, src, image.Point{}, draw.Src)
+					}
+					draw.DrawMask(img, dr, glyphSrc, image.Point{}, mask, maskp, draw.Over)
 					dot.X += advance
 					prevC, prevMargin = c, 0
+					if c != ' ' || i < len(l.segments)-1 {
+						end = dot.X
+					}
+				}
+
+				if start >= 0 {
+					thickness := (metrics.Ascent / 12).Ceil()
+					if s.decoration&decorationStrikethrough != 0 {
+						y := (dot.Y - metrics.Ascent*3/10).Round()
+						strike := image.Rect(start.Round(), y-thickness/2, end.Round(), y-thickness/2+thickness)
+						draw.Draw(img, strike, glyphSrc, image.Point{}, draw.Src)
+					}
+					if s.decoration&decorationUnderline != 0 {
+						y := dot.Y.Round() + thickness
+						underline := image.Rect(start.Round(), y, end.Round(), y+thickness)
+						draw.Draw(img, underline, glyphSrc, image.Point{}, draw.Src)
+					}
 				}
 			case glyphSegment:
 				if prevC >= 0 {
@@ -309,11 +665,7 @@ func printParagraph(output bitmap.Device, contents []content, raw bool) error {
 				dot.X += fixed.I(bounds.Dx())
 				prevC, prevMargin = -1, s.rightMargin
 			case indentSegment:
-				for _, vr := range s.vrules {
-					upperLeft := image.Point{vr.Ceil(), dot.Y.Ceil()}
-					vr := image.Rectangle{upperLeft, upperLeft.Add(image.Point{1, lineHeight.Ceil()})}
-					draw.Draw(img, vr, src, image.Point{}, draw.Src)
-				}
+				drawVRules(img, s.vrules, output.DPI(), outputY(output))
 				indentWidth, dot.X, vrules = s.width, s.width, s.vrules
 				prevC, prevMargin = -1, 0
 			}
@@ -0,0 +1,58 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pgavlin/lilprinty/internal/hyphen"
+)
+
+// showBreaks returns the given word with each break marked: '-' for a preferred break that draws a hyphen, '~' for a
+// forced break that draws a hyphen, and '|' for a preferred break that does not.
+func showBreaks(word string, patterns *hyphen.Patterns) string {
+	runes := []rune(word)
+
+	var b strings.Builder
+	last := 0
+	for _, wb := range wordBreaks([]segment{textSegment{runes: runes}}, patterns) {
+		b.WriteString(string(runes[last:wb.rune]))
+		switch {
+		case !wb.hyphen:
+			b.WriteByte('|')
+		case wb.preferred:
+			b.WriteByte('-')
+		default:
+			b.WriteByte('~')
+		}
+		last = wb.rune
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+func TestWordBreaks(t *testing.T) {
+	cases := []struct {
+		word, expected string
+	}{
+		// Words are hyphenated by the patterns, and may otherwise be broken between any two characters that leave at
+		// least RightMin characters on the next line.
+		{"typesetting", "t~y~p~e-s~e~t-t~ing"},
+		{"typesetting ", "t~y~p~e-s~e~t-t~ing "},
+		{"0123456", "0~1~2~3~456"},
+
+		// Existing hyphens are preferred breaks, and no hyphen is drawn after them.
+		{"well-known", "w~e~l~l-|k~n~own"},
+
+		// URLs and paths are broken after their punctuation rather than hyphenated. Runs of punctuation are kept
+		// together.
+		{"https://example.com/a?b=c&d", "h~t~t~p~s~://|e~x~a~m~p~l~e.|c~o~m/|a?|b=|c&|d"},
+
+		// A word that ends in punctuation is not a URL.
+		{"dictionary.", "d~i~c-t~i~o-n~a~ry."},
+	}
+	for _, c := range cases {
+		if actual := showBreaks(c.word, hyphen.English); actual != c.expected {
+			t.Errorf("%q: expected %q, got %q", c.word, c.expected, actual)
+		}
+	}
+}
@@ -1,26 +1,28 @@
 package markdown
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"math"
 	"net/url"
+	"strings"
+	"unicode"
 
 	"github.com/boombuler/barcode"
-	"github.com/boombuler/barcode/datamatrix"
 	"github.com/pgavlin/goldmark/ast"
-	mdtext "github.com/pgavlin/goldmark/text"
+	east "github.com/pgavlin/goldmark/extension/ast"
 	"golang.org/x/image/math/fixed"
 
 	"github.com/pgavlin/lilprinty/internal/bitmap"
 	"github.com/pgavlin/lilprinty/internal/font"
+	"github.com/pgavlin/lilprinty/internal/highlight"
+	"github.com/pgavlin/lilprinty/internal/hyphen"
+	"github.com/pgavlin/lilprinty/internal/shortener"
 )
 
-const indentAmount = 4.5
-
 type listState struct {
 	node        *ast.List
 	markerWidth float64
@@ -29,35 +31,276 @@ type listState struct {
 
 // BlockStyle describes the style for a block node.
 type BlockStyle struct {
-	PointSize    float64 // The size of the block's font face in points.
-	TopMargin    float64 // The top margin of the block in points.
-	BottomMargin float64 // The bottom margin of the block in points.
+	PointSize    float64   // The size of the block's font face in points.
+	TopMargin    float64   // The top margin of the block in points.
+	BottomMargin float64   // The bottom margin of the block in points.
+	Align        Alignment // The alignment of the block's lines. Code blocks are always aligned left.
+
+	// LineHeight is the height of each line as a multiple of the height of its tallest font face or glyph. Values less
+	// than 1 may clip tall characters. If zero, lines are printed at their natural height.
+	LineHeight float64
+	// LineHeightPoints is the height of each line in points. If non-zero, it takes precedence over LineHeight.
+	LineHeightPoints float64
+	// Tracking is the extra space in points that follows each character. Negative values bring characters closer
+	// together. Code blocks are never tracked.
+	Tracking float64
+}
+
+// A TextStyle describes how a span of text is emphasized.
+type TextStyle struct {
+	Bold      bool // The text is printed in bold.
+	Italic    bool // The text is printed in italics.
+	Underline bool // A line is drawn beneath the text.
+	Invert    bool // The text is printed in white on a black background.
+}
+
+// An InlineCodeStyle describes how code spans are printed.
+type InlineCodeStyle struct {
+	// Scale is the point size of code spans relative to the surrounding text. If zero, 1 is used.
+	Scale float64
+	// Style is added to the emphasis of the surrounding text.
+	Style TextStyle
+}
+
+// A BlockquoteStyle describes how blockquotes are printed.
+type BlockquoteStyle struct {
+	Indent        *float64  // The indent of the blockquote's contents in points. If nil, 4.5 points are used.
+	RuleThickness float64   // The thickness of the rule to the left in points. Rules are at least one dot thick.
+	RuleStyle     RuleStyle // The style of the rule to the left of the blockquote.
+}
+
+// defaultBlockquoteIndent is the indent of a blockquote's contents in points if its style does not give one.
+const defaultBlockquoteIndent = 4.5
+
+// DefaultBlockquoteStyle indents blockquotes by 4.5 points and draws a solid rule one dot thick to their left.
+var DefaultBlockquoteStyle = BlockquoteStyle{RuleStyle: RuleSolid}
+
+// A ListStyle describes how lists are printed.
+type ListStyle struct {
+	Indent    float64 // The indent of each list item's marker in points.
+	MarkerGap float64 // The space between each list item's marker and its contents in points.
+
+	// Bullets holds the marker for the items of unordered lists at each level of nesting. Lists that are nested more
+	// deeply than there are bullets cycle back to the first bullet.
+	Bullets []string
+	// OrderedFormats holds the format of the markers for the items of ordered lists at each level of nesting, e.g.
+	// "1.", "a)", or "(i)". The first occurrence of '1', 'a', 'A', 'i', or 'I' is replaced with the item's number as a
+	// decimal number, a lower- or uppercase letter, or a lower- or uppercase Roman numeral, respectively. Lists that
+	// are nested more deeply than there are formats cycle back to the first format.
+	OrderedFormats []string
+}
+
+// DefaultListStyle indents list markers by 4.5 points, marks unordered list items with bullets, and numbers ordered
+// list items with decimal numbers.
+var DefaultListStyle = ListStyle{
+	Indent:         4.5,
+	MarkerGap:      2.5,
+	Bullets:        []string{"•"},
+	OrderedFormats: []string{"1."},
+}
+
+// A ThematicBreakStyle describes how thematic breaks are printed.
+type ThematicBreakStyle struct {
+	Thickness float64   // The thickness of the rule in points. Rules are at least one dot thick.
+	Style     RuleStyle // The style of the rule.
+	Margin    *float64  // The space above and below the rule in points. If nil, half the paragraph point size is used.
+}
+
+// DefaultHighlight prints keywords in bold, comments in italics, and strings underlined.
+var DefaultHighlight = map[highlight.Kind]TextStyle{
+	highlight.Keyword: {Bold: true},
+	highlight.Comment: {Italic: true},
+	highlight.String:  {Underline: true},
+}
+
+// Style describes the fonts and styles used to render a document.
+type Style struct {
+	ProportionalFamily *font.Family // The font family used for body text.
+	MonospaceFamily    *font.Family // The font family used for code.
+
+	// BaseDir is the directory against which relative image paths are resolved. Local images are only loaded from
+	// within BaseDir; if it is empty, images may only be downloaded over HTTP or HTTPS.
+	BaseDir string
+
+	// FallbackFamilies are consulted in order for characters that the proportional or monospace family lacks, such as
+	// emoji or CJK characters. Characters that no family has are printed as a replacement box.
+	FallbackFamilies []*font.Family
+
+	HeadingStyles  []BlockStyle // The styles for each heading level.
+	ParagraphStyle BlockStyle   // The style for paragraphs.
+
+	// Shortener shortens link URLs before they are encoded as link codes. If nil, URLs are encoded in full.
+	Shortener shortener.Shortener
+	// LinkCode describes how links are encoded. If its symbology is empty, DefaultLinkCode is used.
+	LinkCode LinkCode
+	// PrintLinkURLs prints the encoded URL in monospace beneath each link code.
+	PrintLinkURLs bool
+	// Highlight maps each kind of token in a fenced code block to the style used to print it. Code blocks are only
+	// highlighted if their language is supported by the highlight package. If nil, DefaultHighlight is used.
+	Highlight map[highlight.Kind]TextStyle
+	// CodeOverflow describes how code blocks with lines that are too wide for the paper are printed. If empty,
+	// CodeOverflowWrap is used.
+	CodeOverflow CodeOverflow
+	// MinCodePointSize is the smallest point size used by CodeOverflowShrink. If zero, DefaultMinCodePointSize is
+	// used.
+	MinCodePointSize float64
+	// Hyphenation holds the patterns used to hyphenate words that are too wide to fit on a line. Words that cannot be
+	// hyphenated are broken between characters. If nil, hyphen.English is used.
+	Hyphenation *hyphen.Patterns
+
+	// CodeBlockStyle is the style for code blocks. If its point size is zero, ParagraphStyle is used.
+	CodeBlockStyle BlockStyle
+	// InlineCode describes how code spans are printed.
+	InlineCode InlineCodeStyle
+	// Blockquote describes how blockquotes are printed. Zero fields take their values from DefaultBlockquoteStyle.
+	Blockquote BlockquoteStyle
+	// List describes how lists are printed. Zero fields take their values from DefaultListStyle.
+	List ListStyle
+	// ThematicBreak describes how thematic breaks are printed. If its style is empty, RuleSolid is used.
+	ThematicBreak ThematicBreakStyle
+	// LinkCodeHeight is the smallest height of a link code in points. If zero, link codes are at least as tall as the
+	// point size of the surrounding text.
+	LinkCodeHeight float64
+	// LinkCodeModuleSize is the smallest size of each module of a link code in dots. If zero, 2 is used.
+	LinkCodeModuleSize int
 }
 
 type Renderer struct {
 	proportionalFamily *font.Family
 	monospaceFamily    *font.Family
-
-	headingStyles  []BlockStyle
-	paragraphStyle BlockStyle
+	fallbackFamilies   []*font.Family
+	baseDir            string
+
+	headingStyles    []BlockStyle
+	paragraphStyle   BlockStyle
+	shortener        shortener.Shortener
+	linkCode         LinkCode
+	printLinkURLs    bool
+	highlight        map[highlight.Kind]TextStyle
+	codeOverflow     CodeOverflow
+	minCodePointSize float64
+	hyphenation      *hyphen.Patterns
+
+	codeBlockStyle      BlockStyle
+	inlineCode          InlineCodeStyle
+	blockquoteStyle     BlockquoteStyle
+	blockquoteIndent    float64
+	listStyle           ListStyle
+	thematicBreak       ThematicBreakStyle
+	thematicBreakMargin float64
+	linkCodeHeight      float64
+	linkCodeModuleSize  int
 
 	listStack   []listState
 	faceStack   []*font.Face
+	decoration  decoration
+	codeSpan    decoration // The decorations added by the current code span.
 	paragraph   []content
-	vrules      []float64
+	vrules      []vrule
 	indentWidth float64
 }
 
-func NewRenderer(proportionalFamily, monospaceFamily *font.Family, headingStyles []BlockStyle, paragraphStyle BlockStyle) *Renderer {
+func NewRenderer(style Style) *Renderer {
+	s := style.Shortener
+	if s == nil {
+		s = shortener.None{}
+	}
+	linkCode := style.LinkCode
+	if linkCode.Symbology == "" {
+		linkCode = DefaultLinkCode
+	}
+	highlightStyle := style.Highlight
+	if highlightStyle == nil {
+		highlightStyle = DefaultHighlight
+	}
+	codeOverflow := style.CodeOverflow
+	if codeOverflow == "" {
+		codeOverflow = CodeOverflowWrap
+	}
+	minCodePointSize := style.MinCodePointSize
+	if minCodePointSize == 0 {
+		minCodePointSize = DefaultMinCodePointSize
+	}
+	hyphenation := style.Hyphenation
+	if hyphenation == nil {
+		hyphenation = hyphen.English
+	}
+	codeBlockStyle := style.CodeBlockStyle
+	if codeBlockStyle.PointSize == 0 {
+		codeBlockStyle = style.ParagraphStyle
+	}
+	inlineCode := style.InlineCode
+	if inlineCode.Scale == 0 {
+		inlineCode.Scale = 1
+	}
+	blockquoteStyle, blockquoteIndent := style.Blockquote, defaultBlockquoteIndent
+	if blockquoteStyle.Indent != nil {
+		blockquoteIndent = *blockquoteStyle.Indent
+	}
+	if blockquoteStyle.RuleStyle == "" {
+		blockquoteStyle.RuleStyle = DefaultBlockquoteStyle.RuleStyle
+	}
+	listStyle := style.List
+	if listStyle.Indent == 0 {
+		listStyle.Indent = DefaultListStyle.Indent
+	}
+	if listStyle.MarkerGap == 0 {
+		listStyle.MarkerGap = DefaultListStyle.MarkerGap
+	}
+	if len(listStyle.Bullets) == 0 {
+		listStyle.Bullets = DefaultListStyle.Bullets
+	}
+	if len(listStyle.OrderedFormats) == 0 {
+		listStyle.OrderedFormats = DefaultListStyle.OrderedFormats
+	}
+	thematicBreak := style.ThematicBreak
+	if thematicBreak.Style == "" {
+		thematicBreak.Style = RuleSolid
+	}
+	thematicBreakMargin := style.ParagraphStyle.PointSize / 2
+	if thematicBreak.Margin != nil {
+		thematicBreakMargin = *thematicBreak.Margin
+	}
+	linkCodeModuleSize := style.LinkCodeModuleSize
+	if linkCodeModuleSize == 0 {
+		linkCodeModuleSize = 2
+	}
+
 	return &Renderer{
-		proportionalFamily: proportionalFamily,
-		monospaceFamily:    monospaceFamily,
-		headingStyles:      headingStyles,
-		paragraphStyle:     paragraphStyle,
+		proportionalFamily:  style.ProportionalFamily,
+		monospaceFamily:     style.MonospaceFamily,
+		fallbackFamilies:    style.FallbackFamilies,
+		baseDir:             style.BaseDir,
+		headingStyles:       style.HeadingStyles,
+		paragraphStyle:      style.ParagraphStyle,
+		shortener:           s,
+		linkCode:            linkCode,
+		printLinkURLs:       style.PrintLinkURLs,
+		highlight:           highlightStyle,
+		codeOverflow:        codeOverflow,
+		minCodePointSize:    minCodePointSize,
+		hyphenation:         hyphenation,
+		codeBlockStyle:      codeBlockStyle,
+		inlineCode:          inlineCode,
+		blockquoteStyle:     blockquoteStyle,
+		blockquoteIndent:    blockquoteIndent,
+		listStyle:           listStyle,
+		thematicBreak:       thematicBreak,
+		thematicBreakMargin: thematicBreakMargin,
+		linkCodeHeight:      style.LinkCodeHeight,
+		linkCodeModuleSize:  linkCodeModuleSize,
 	}
 }
 
 func (r *Renderer) Render(device bitmap.Device, source []byte, n ast.Node) error {
+	if _, ok := device.(*trackingDevice); !ok {
+		device = &trackingDevice{Device: device}
+	}
+	return r.walk(device, source, n)
+}
+
+// walk renders the given node and its descendants to the given Device.
+func (r *Renderer) walk(device bitmap.Device, source []byte, n ast.Node) error {
 	return ast.Walk(n, func(n ast.Node, enter bool) (ast.WalkStatus, error) {
 		switch n := n.(type) {
 		case *ast.Document:
@@ -82,6 +325,10 @@ func (r *Renderer) Render(device bitmap.Device, source []byte, n ast.Node) error
 			return r.renderTextBlock(device, source, n, enter)
 		case *ast.ThematicBreak:
 			return r.renderThematicBreak(device, source, n, enter)
+		case *Barcode:
+			return r.renderBarcode(device, source, n, enter)
+		case *east.Table:
+			return r.renderTable(device, source, n, enter)
 
 		// inlines
 		case *ast.AutoLink:
@@ -98,6 +345,10 @@ func (r *Renderer) Render(device bitmap.Device, source []byte, n ast.Node) error
 			return r.renderText(device, source, n, enter)
 		case *ast.String:
 			return r.renderString(device, source, n, enter)
+		case *east.Strikethrough:
+			return r.renderStrikethrough(device, source, n, enter)
+		case *east.TaskCheckBox:
+			return r.renderTaskCheckBox(device, source, n, enter)
 		}
 
 		return ast.WalkContinue, nil
@@ -125,12 +376,7 @@ func (r *Renderer) printMargin(device bitmap.Device, points float64) error {
 
 	margin := bitmap.New(image.Rect(0, 0, device.MaxWidth(), lines))
 	draw.Draw(margin, margin.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
-
-	for _, vr := range r.vrules {
-		upperLeft := image.Point{floatToFixed(vr / 72.0 * dpi).Ceil(), 0}
-		vr := image.Rectangle{upperLeft, upperLeft.Add(image.Point{1, lines})}
-		draw.Draw(margin, vr, image.NewUniform(color.Black), image.Point{}, draw.Src)
-	}
+	r.drawVRules(device, margin)
 	return device.PrintBitmap(margin)
 }
 
@@ -138,7 +384,50 @@ func (r *Renderer) appendContent(c ...content) {
 	if len(r.paragraph) == 0 && r.indentWidth != 0 {
 		r.paragraph = append(r.paragraph, indent{vrules: r.vrules, points: r.indentWidth})
 	}
-	r.paragraph = append(r.paragraph, c...)
+	for _, item := range c {
+		if t, ok := item.(text); ok {
+			r.paragraph = append(r.paragraph, r.withFallbacks(t)...)
+		} else {
+			r.paragraph = append(r.paragraph, item)
+		}
+	}
+}
+
+// withFallbacks splits the given text into runs that are each printed in a single face. Characters that the text's
+// face lacks are printed in the face of the same size and style from the first fallback family that has them.
+// Characters that no family has stay in the text's face, which prints them as a replacement box. Spaces and control
+// characters stay in the run that precedes them.
+func (r *Renderer) withFallbacks(t text) []content {
+	primary, ok := t.face.(*font.Face)
+	if !ok || len(r.fallbackFamilies) == 0 {
+		return []content{t}
+	}
+
+	var runs []content
+	face, start := primary, 0
+	for i, c := range string(t.bytes) {
+		if !unicode.IsGraphic(c) || unicode.IsSpace(c) {
+			continue
+		}
+
+		runeFace := primary
+		if !primary.HasGlyph(c) {
+			for _, family := range r.fallbackFamilies {
+				if fallback := family.Face(primary.Size(), primary.Bold(), primary.Italic()); fallback.HasGlyph(c) {
+					runeFace = fallback
+					break
+				}
+			}
+		}
+
+		if runeFace != face {
+			if i > start {
+				runs = append(runs, text{face: face, bytes: t.bytes[start:i], decoration: t.decoration})
+			}
+			face, start = runeFace, i
+		}
+	}
+	return append(runs, text{face: face, bytes: t.bytes[start:], decoration: t.decoration})
 }
 
 func (r *Renderer) printParagraph(device bitmap.Device, style BlockStyle, raw bool) error {
@@ -146,7 +435,12 @@ func (r *Renderer) printParagraph(device bitmap.Device, style BlockStyle, raw bo
 		if err := r.printMargin(device, style.TopMargin); err != nil {
 			return err
 		}
-		if err := printParagraph(device, r.paragraph, raw); err != nil {
+		// Raw paragraphs hold code, which is never hyphenated, aligned, or tracked.
+		patterns := r.hyphenation
+		if raw {
+			style.Align, style.Tracking, patterns = AlignLeft, 0, nil
+		}
+		if err := printParagraph(device, r.paragraph, raw, style, patterns); err != nil {
 			return err
 		}
 		if err := r.printMargin(device, style.BottomMargin); err != nil {
@@ -157,6 +451,13 @@ func (r *Renderer) printParagraph(device bitmap.Device, style BlockStyle, raw bo
 	return nil
 }
 
+// flushParagraph prints any pending paragraph content, such as the marker of a list item, on a line of its own. Blocks
+// that print directly to the device rather than through the current paragraph call it first so that the pending
+// content is neither lost nor printed along with a later paragraph.
+func (r *Renderer) flushParagraph(device bitmap.Device) error {
+	return r.printParagraph(device, BlockStyle{}, false)
+}
+
 // renderDocument renders an *ast.Document node to the given Device.
 func (r *Renderer) renderDocument(device bitmap.Device, source []byte, node *ast.Document, enter bool) (ast.WalkStatus, error) {
 	if enter {
@@ -191,29 +492,21 @@ func (r *Renderer) renderHeading(device bitmap.Device, source []byte, node *ast.
 
 // renderBlockquote renders an *ast.Blockquote node to the given Device.
 func (r *Renderer) renderBlockquote(device bitmap.Device, source []byte, node *ast.Blockquote, enter bool) (ast.WalkStatus, error) {
+	style := r.blockquoteStyle
 	if enter {
-		r.vrules = append(r.vrules, r.indentWidth)
-		r.indentWidth += indentAmount
+		r.vrules = append(r.vrules, vrule{x: r.indentWidth, thickness: style.RuleThickness, style: style.RuleStyle})
+		r.indentWidth += r.blockquoteIndent
 	} else {
 		r.vrules = r.vrules[:len(r.vrules)-1]
-		r.indentWidth -= indentAmount
+		r.indentWidth -= r.blockquoteIndent
 	}
 	return ast.WalkContinue, nil
 }
 
-func (r *Renderer) renderCode(device bitmap.Device, source []byte, lines *mdtext.Segments) error {
-	face := r.monospaceFamily.Size(r.paragraphStyle.PointSize).Regular()
-	for i := 0; i < lines.Len(); i++ {
-		line := lines.At(i)
-		r.appendContent(text{face: face, bytes: line.Value(source)})
-	}
-	return r.printParagraph(device, r.paragraphStyle, true)
-}
-
 // renderCodeBlock renders an *ast.CodeBlock node to the given Device.
 func (r *Renderer) renderCodeBlock(device bitmap.Device, source []byte, node *ast.CodeBlock, enter bool) (ast.WalkStatus, error) {
 	if enter {
-		if err := r.renderCode(device, source, node.Lines()); err != nil {
+		if err := r.renderCode(device, codeText(source, node.Lines()), ""); err != nil {
 			return ast.WalkStop, err
 		}
 	}
@@ -223,37 +516,53 @@ func (r *Renderer) renderCodeBlock(device bitmap.Device, source []byte, node *as
 // renderFencedCodeBlock renders an *ast.FencedCodeBlock node to the given Device.
 func (r *Renderer) renderFencedCodeBlock(device bitmap.Device, source []byte, node *ast.FencedCodeBlock, enter bool) (ast.WalkStatus, error) {
 	if enter {
-		if err := r.renderCode(device, source, node.Lines()); err != nil {
+		if err := r.renderCode(device, codeText(source, node.Lines()), string(node.Language(source))); err != nil {
 			return ast.WalkStop, err
 		}
 	}
 	return ast.WalkSkipChildren, nil
 }
 
+// measureMarker measures the given list marker in the given face. Like the marker itself, the measurement uses the
+// fallback families for any characters that the face lacks.
+func (r *Renderer) measureMarker(face *font.Face, marker string) fixed.Int26_6 {
+	var word []segment
+	for _, run := range r.withFallbacks(text{face: face, bytes: []byte(marker)}) {
+		t := run.(text)
+		word = append(word, textSegment{face: t.face, runes: []rune(string(t.bytes))})
+	}
+	_, width := measureWord(line{}, word)
+	return width
+}
+
 // renderList renders an *ast.List node to the given Device.
 func (r *Renderer) renderList(device bitmap.Device, source []byte, node *ast.List, enter bool) (ast.WalkStatus, error) {
 	if enter {
+		r.listStack = append(r.listStack, listState{node: node, index: node.Start})
+
 		// Measure the maximum marker width.
 		face := r.proportionalFamily.Size(r.paragraphStyle.PointSize).Regular()
 
 		var markerWidth fixed.Int26_6
 		if node.IsOrdered() {
 			for i := 0; i < node.ChildCount(); i++ {
-				runes := []rune(fmt.Sprintf("%d.", i+node.Start))
-				_, width := measureWord(line{}, []segment{textSegment{face: face, runes: runes}})
-				if width > markerWidth {
+				if width := r.measureMarker(face, r.listMarker(i+node.Start)); width > markerWidth {
 					markerWidth = width
 				}
 			}
 		} else {
-			_, markerWidth = measureWord(line{}, []segment{textSegment{face: face, runes: []rune("•")}})
+			markerWidth = r.measureMarker(face, r.listMarker(0))
+			for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+				if taskCheckBox(item) != nil {
+					if width := fixed.I(checkBox(face, false).Bounds().Dx()); width > markerWidth {
+						markerWidth = width
+					}
+					break
+				}
+			}
 		}
 
-		r.listStack = append(r.listStack, listState{
-			node:        node,
-			markerWidth: fixedToFloat(markerWidth) / device.DPI() * 72.0,
-			index:       node.Start,
-		})
+		r.listStack[len(r.listStack)-1].markerWidth = fixedToFloat(markerWidth) / device.DPI() * 72.0
 	} else {
 		r.listStack = r.listStack[:len(r.listStack)-1]
 	}
@@ -265,24 +574,26 @@ func (r *Renderer) renderList(device bitmap.Device, source []byte, node *ast.Lis
 func (r *Renderer) renderListItem(device bitmap.Device, source []byte, node *ast.ListItem, enter bool) (ast.WalkStatus, error) {
 	state := &r.listStack[len(r.listStack)-1]
 	if enter {
-		// Set the font and write the marker.
-		var buf bytes.Buffer
-		if state.node.IsOrdered() {
-			fmt.Fprintf(&buf, "%d.", state.index)
-			state.index++
+		// Set the font and write the marker. Task list items in unordered lists use their checkbox as their marker.
+		// Like other text, the marker is printed using the fallback families for any characters the face lacks.
+		face := r.proportionalFamily.Size(r.paragraphStyle.PointSize).Regular()
+
+		var marker content
+		if box := taskCheckBox(node); box != nil && !state.node.IsOrdered() {
+			marker = glyph{bits: checkBox(face, box.IsChecked)}
 		} else {
-			fmt.Fprintf(&buf, "•")
+			marker = text{face: face, bytes: []byte(r.listMarker(state.index))}
+			state.index++
 		}
 
-		face := r.proportionalFamily.Size(r.paragraphStyle.PointSize).Regular()
+		style := r.listStyle
+		r.appendContent(indent{points: r.indentWidth + style.Indent},
+			marker,
+			indent{points: r.indentWidth + style.Indent + state.markerWidth + style.MarkerGap})
 
-		r.appendContent(indent{points: r.indentWidth + indentAmount},
-			text{face: face, bytes: buf.Bytes()},
-			indent{points: r.indentWidth + indentAmount + state.markerWidth + 2.5})
-
-		r.indentWidth += indentAmount + state.markerWidth + 2.5
+		r.indentWidth += style.Indent + state.markerWidth + style.MarkerGap
 	} else {
-		r.indentWidth -= indentAmount + state.markerWidth + 2.5
+		r.indentWidth -= r.listStyle.Indent + state.markerWidth + r.listStyle.MarkerGap
 	}
 
 	return ast.WalkContinue, nil
@@ -322,10 +633,11 @@ func (r *Renderer) renderTextBlock(device bitmap.Device, source []byte, node *as
 func (r *Renderer) renderThematicBreak(device bitmap.Device, source []byte, node *ast.ThematicBreak, enter bool) (ast.WalkStatus, error) {
 	// Write a horizontal rule
 	if enter {
-		margin := int(math.Ceil(r.paragraphStyle.PointSize / 72.0 * device.DPI() / 2))
-		img := bitmap.New(image.Rect(0, 0, device.MaxWidth(), margin*2+1))
+		margin := int(math.Ceil(r.thematicBreakMargin / 72.0 * device.DPI()))
+		thickness := ruleThickness(r.thematicBreak.Thickness, device.DPI())
+		img := bitmap.New(image.Rect(0, 0, device.MaxWidth(), margin*2+thickness))
 		draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
-		draw.Draw(img, image.Rect(0, margin, device.MaxWidth(), margin+1), image.NewUniform(color.Black), image.Point{}, draw.Src)
+		drawRule(img, image.Rect(0, margin, device.MaxWidth(), margin+thickness), r.thematicBreak.Style, false, 0)
 		if err := device.PrintBitmap(img); err != nil {
 			return ast.WalkStop, err
 		}
@@ -333,24 +645,134 @@ func (r *Renderer) renderThematicBreak(device bitmap.Device, source []byte, node
 	return ast.WalkContinue, nil
 }
 
-func (r *Renderer) renderURLAsDataMatrix(device bitmap.Device, url string) (*bitmap.Image, error) {
-	// Shorten the URL first.
-	url, err := shortenURL(url)
+// barcodeModuleSize and barcodeHeight are the default width of a barcode's narrowest bar or module and the height of a
+// one-dimensional barcode, respectively, in points.
+const barcodeModuleSize, barcodeHeight = 0.75, 36
+
+// renderBarcode renders a *Barcode node to the given Device.
+func (r *Renderer) renderBarcode(device bitmap.Device, source []byte, node *Barcode, enter bool) (ast.WalkStatus, error) {
+	if !enter {
+		return ast.WalkContinue, nil
+	}
+
+	code, text, err := node.encode()
+	if err != nil {
+		return ast.WalkStop, fmt.Errorf("encoding barcode: %w", err)
+	}
+
+	indent := int(math.Ceil(r.indentWidth / 72.0 * device.DPI()))
+	available := device.MaxWidth() - indent
+	modules := code.Bounds().Dx()
+	oneDimensional := code.Bounds().Dy() == 1
+	code, err = scaleBarcode(code, node.FullWidth, available, device.DPI(), barcodeModuleSize, barcodeHeight)
+	if err != nil {
+		return ast.WalkStop, fmt.Errorf("encoding barcode: %w", err)
+	}
+
+	bits := bitmap.ForDevice(device, code, false)
+	barHeight := bits.Bounds().Dy()
+	if node.PrintText {
+		face := r.monospaceFamily.Size(r.paragraphStyle.PointSize).Regular()
+		bits = captionBarcode(bits, face, strings.Replace(text, "\n", " ", -1))
+	}
+
+	if err := r.printMargin(device, r.paragraphStyle.TopMargin); err != nil {
+		return ast.WalkStop, err
+	}
+	if err := r.flushParagraph(device); err != nil {
+		return ast.WalkStop, err
+	}
+
+	// Center the barcode in the space to the right of the current indent.
+	bounds := bits.Bounds()
+	img := bitmap.New(image.Rect(0, 0, device.MaxWidth(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	r.drawVRules(device, img)
+	left := indent + (available-bounds.Dx())/2
+	draw.Draw(img, bounds.Sub(bounds.Min).Add(image.Point{left, 0}), bits, bounds.Min, draw.Src)
+
+	// Print the barcode natively if the device supports it. Native barcodes are always centered on the paper, so
+	// indented barcodes are printed as bitmaps.
+	native, ok := device.(bitmap.BarcodeDevice)
+	request := bitmap.Barcode{
+		Symbology:   string(node.Symbology),
+		Data:        text,
+		Modules:     modules,
+		ModuleWidth: code.Bounds().Dx() / modules,
+		Height:      barHeight,
+		PrintText:   node.PrintText,
+		Raster:      img,
+	}
+	if ok && oneDimensional && r.indentWidth == 0 && native.SupportsBarcode(request) {
+		err = native.PrintBarcode(request)
+	} else {
+		err = device.PrintBitmap(img)
+	}
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	if err := r.printMargin(device, r.paragraphStyle.BottomMargin); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// errLinkTooLarge is returned when a URL is too long to encode in a link code that fits on the paper.
+var errLinkTooLarge = errors.New("link code is too large to print")
+
+// checkLinkURL returns an error if the given URL cannot be encoded as a link code. Only absolute HTTP and HTTPS URLs
+// are encoded.
+func checkLinkURL(urlString string) error {
+	parsed, err := url.Parse(urlString)
 	if err != nil {
+		return err
+	}
+	if !parsed.IsAbs() || parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return &url.Error{
+			Op:  "parse",
+			URL: urlString,
+			Err: fmt.Errorf("only absolute HTTP and HTTPS URLs can be encoded"),
+		}
+	}
+	return nil
+}
+
+// renderURLAsLinkCode encodes the given URL as a two-dimensional barcode using the given link code.
+func (r *Renderer) renderURLAsLinkCode(device bitmap.Device, url string, linkCode LinkCode) (*bitmap.Image, error) {
+	if err := checkLinkURL(url); err != nil {
 		return nil, err
 	}
 
-	// Render the shortened URL to a data matrix barcode.
-	code, err := datamatrix.Encode(url)
+	// Shorten the URL first. If the shortener is unavailable, encode the URL in full rather than dropping the link.
+	if short, err := r.shortener.Shorten(url); err == nil {
+		url = short
+	}
+
+	// Render the shortened URL to a barcode. The encoders pick the smallest symbol that fits the URL.
+	code, err := linkCode.encode(url)
 	if err != nil {
 		return nil, err
 	}
 
-	// Scale the barcode up as necessary for legibility. Doubling the area of each pixel seems to be enough.
-	pointSize := r.face().Size()
-	pixelHeight := int(math.Ceil(pointSize / 72.0 * device.DPI()))
-	if pixelHeight < code.Bounds().Dy()*2 {
-		pixelHeight = code.Bounds().Dy() * 2
+	// Scale the barcode up as necessary for legibility. Doubling the area of each pixel seems to be enough, but long
+	// URLs produce large symbols, so limit the scale to what fits in the space left by the current indent.
+	available := device.MaxWidth() - int(math.Ceil(r.indentWidth/72.0*device.DPI()))
+	size := code.Bounds().Dy()
+	if size > available {
+		return nil, errLinkTooLarge
+	}
+
+	pointSize, height := r.face().Size(), r.linkCodeHeight
+	if height == 0 {
+		height = pointSize
+	}
+	pixelHeight := int(math.Ceil(height / 72.0 * device.DPI()))
+	if pixelHeight < size*r.linkCodeModuleSize {
+		pixelHeight = size * r.linkCodeModuleSize
+	}
+	if pixelHeight > available {
+		pixelHeight = available / size * size
 	}
 	code, err = barcode.Scale(code, pixelHeight, pixelHeight)
 	if err != nil {
@@ -358,22 +780,76 @@ func (r *Renderer) renderURLAsDataMatrix(device bitmap.Device, url string) (*bit
 	}
 
 	// Convert the barcode to a bitmap.
-	return bitmap.ForDevice(device, code, false), nil
+	bits := bitmap.ForDevice(device, code, false)
+	if r.printLinkURLs {
+		// Keep the caption small and narrow so that the code still fits alongside the surrounding text.
+		captionWidth := available / 2
+		if bits.Bounds().Dx() > captionWidth {
+			captionWidth = bits.Bounds().Dx()
+		}
+		face := r.monospaceFamily.Size(pointSize * 0.75).Regular()
+		bits = captionLinkCode(bits, face, url, captionWidth)
+	}
+	return bits, nil
+}
+
+// appendLinkCode appends a link code for the given destination to the current paragraph. Links whose destinations are
+// not absolute HTTP or HTTPS URLs or whose codes do not fit in the space available are skipped.
+func (r *Renderer) appendLinkCode(device bitmap.Device, destination, title string) (ast.WalkStatus, error) {
+	linkCode, destination := overrideLinkCode(r.linkCode, destination, title)
+	if linkCode.Symbology == SymbologyNone {
+		return ast.WalkContinue, nil
+	}
+
+	code, err := r.renderURLAsLinkCode(device, destination, linkCode)
+	if err != nil {
+		// checkLinkURL reports destinations that cannot be encoded as *url.Errors.
+		if _, ok := err.(*url.Error); ok || errors.Is(err, errLinkTooLarge) {
+			return ast.WalkContinue, nil
+		}
+		return ast.WalkStop, err
+	}
+	r.appendContent(glyph{
+		bits: code,
+	})
+	return ast.WalkContinue, nil
 }
 
 // renderAutoLink renders an *ast.AutoLink node to the given Device.
 func (r *Renderer) renderAutoLink(device bitmap.Device, source []byte, node *ast.AutoLink, enter bool) (ast.WalkStatus, error) {
-	// data matrix
-	return ast.WalkContinue, nil
+	if !enter {
+		return ast.WalkContinue, nil
+	}
+
+	// Autolinks have no children, so print their label here.
+	r.appendContent(text{
+		face:       r.face(),
+		bytes:      node.Label(source),
+		decoration: r.decoration,
+	})
+	return r.appendLinkCode(device, string(node.URL(source)), "")
 }
 
 // renderCodeSpan renders an *ast.CodeSpan node to the given Device.
 func (r *Renderer) renderCodeSpan(device bitmap.Device, source []byte, node *ast.CodeSpan, enter bool) (ast.WalkStatus, error) {
+	style := r.inlineCode.Style
 	if enter {
-		// Push the appropriate monospace font face.
+		// Push the appropriate monospace font face and add the code span's decorations.
 		current := r.face()
-		r.pushFace(r.monospaceFamily.Face(current.Size(), current.Bold(), current.Italic()))
+		r.pushFace(r.monospaceFamily.Face(current.Size()*r.inlineCode.Scale, current.Bold() || style.Bold,
+			current.Italic() || style.Italic))
+
+		var d decoration
+		if style.Underline {
+			d |= decorationUnderline
+		}
+		if style.Invert {
+			d |= decorationInvert
+		}
+		r.codeSpan = d &^ r.decoration
+		r.decoration |= r.codeSpan
 	} else {
+		r.decoration &^= r.codeSpan
 		r.popFace()
 	}
 	return ast.WalkContinue, nil
@@ -396,35 +872,33 @@ func (r *Renderer) renderEmphasis(device bitmap.Device, source []byte, node *ast
 
 // renderImage renders an *ast.Image node to the given Device.
 func (r *Renderer) renderImage(device bitmap.Device, source []byte, node *ast.Image, enter bool) (ast.WalkStatus, error) {
-	// Download the image.
-	img, err := downloadImage(string(node.Destination))
+	if !enter {
+		return ast.WalkContinue, nil
+	}
+
+	// Load the image.
+	img, err := loadImage(r.baseDir, string(node.Destination))
 	if err != nil {
 		// Ignore failures; just print the empty set character.
 		r.appendContent(text{
-			face:  r.face(),
-			bytes: []byte("∅"),
+			face:       r.face(),
+			bytes:      []byte("∅"),
+			decoration: r.decoration,
 		})
 	} else {
 		r.appendContent(glyph{
 			bits: bitmap.ForDevice(device, img, true),
 		})
 	}
-	return ast.WalkContinue, nil
+
+	// The image's children are its alt text, which is not printed.
+	return ast.WalkSkipChildren, nil
 }
 
 // renderLink renders an *ast.Link node to the given Device.
 func (r *Renderer) renderLink(device bitmap.Device, source []byte, node *ast.Link, enter bool) (ast.WalkStatus, error) {
 	if !enter {
-		dataMatrix, err := r.renderURLAsDataMatrix(device, string(node.Destination))
-		if err != nil {
-			if _, ok := err.(*url.Error); ok {
-				return ast.WalkContinue, nil
-			}
-			return ast.WalkStop, err
-		}
-		r.appendContent(glyph{
-			bits: dataMatrix,
-		})
+		return r.appendLinkCode(device, string(node.Destination), string(node.Title))
 	}
 	return ast.WalkContinue, nil
 }
@@ -437,18 +911,21 @@ func (r *Renderer) renderText(device bitmap.Device, source []byte, node *ast.Tex
 
 	// Append the text to the current paragraph using the current font face.
 	r.appendContent(text{
-		face:  r.face(),
-		bytes: node.Segment.Value(source),
+		face:       r.face(),
+		bytes:      node.Segment.Value(source),
+		decoration: r.decoration,
 	})
 
+	// Hard line breaks are also marked as soft line breaks, so check for them first.
 	switch {
+	case node.HardLineBreak():
+		r.paragraph = append(r.paragraph, linebreak{})
 	case node.SoftLineBreak():
 		r.appendContent(text{
-			face:  r.face(),
-			bytes: []byte{' '},
+			face:       r.face(),
+			bytes:      []byte{' '},
+			decoration: r.decoration,
 		})
-	case node.HardLineBreak():
-		r.paragraph = append(r.paragraph, linebreak{})
 	}
 
 	return ast.WalkContinue, nil
@@ -462,9 +939,39 @@ func (r *Renderer) renderString(device bitmap.Device, source []byte, node *ast.S
 
 	// Append the text to the current paragraph using the current font face.
 	r.appendContent(text{
-		face:  r.face(),
-		bytes: node.Value,
+		face:       r.face(),
+		bytes:      node.Value,
+		decoration: r.decoration,
 	})
 
 	return ast.WalkContinue, nil
 }
+
+// renderStrikethrough renders an *east.Strikethrough node to the given Device.
+func (r *Renderer) renderStrikethrough(device bitmap.Device, source []byte, node *east.Strikethrough, enter bool) (ast.WalkStatus, error) {
+	if enter {
+		r.decoration |= decorationStrikethrough
+	} else {
+		r.decoration &^= decorationStrikethrough
+	}
+	return ast.WalkContinue, nil
+}
+
+// renderTaskCheckBox renders an *east.TaskCheckBox node to the given Device.
+func (r *Renderer) renderTaskCheckBox(device bitmap.Device, source []byte, node *east.TaskCheckBox, enter bool) (ast.WalkStatus, error) {
+	if !enter {
+		return ast.WalkContinue, nil
+	}
+
+	// Checkboxes in unordered lists replace the list marker, and have already been drawn.
+	if item, ok := node.Parent().Parent().(*ast.ListItem); ok && !item.Parent().(*ast.List).IsOrdered() {
+		return ast.WalkContinue, nil
+	}
+
+	face := r.face()
+	r.appendContent(glyph{
+		bits:        checkBox(face, node.IsChecked),
+		rightMargin: face.Size() / 4,
+	})
+	return ast.WalkContinue, nil
+}
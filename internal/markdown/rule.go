@@ -0,0 +1,130 @@
+package markdown
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// A RuleStyle describes how a rule is drawn.
+type RuleStyle string
+
+const (
+	// RuleSolid draws a continuous line.
+	RuleSolid RuleStyle = "solid"
+	// RuleDashed draws a line of dashes three times as long as the rule is thick.
+	RuleDashed RuleStyle = "dashed"
+	// RuleDotted draws a line of square dots.
+	RuleDotted RuleStyle = "dotted"
+	// RuleDouble draws two parallel lines, each a third as thick as the rule. Rules that are less than three dots
+	// thick are drawn solid.
+	RuleDouble RuleStyle = "double"
+	// RuleNone draws nothing.
+	RuleNone RuleStyle = "none"
+)
+
+// ruleThickness returns the thickness of a rule in dots. Rules are at least one dot thick.
+func ruleThickness(points, dpi float64) int {
+	if dots := int(math.Round(points / 72.0 * dpi)); dots > 1 {
+		return dots
+	}
+	return 1
+}
+
+// drawRule draws a horizontal or vertical rule that fills the given rectangle. The rule's pattern begins phase dots
+// before the start of the rectangle, so that a rule that is drawn in pieces continues its pattern from one piece to the
+// next.
+func drawRule(dst draw.Image, r image.Rectangle, style RuleStyle, vertical bool, phase int) {
+	black := image.NewUniform(color.Black)
+
+	thickness, length := r.Dy(), r.Dx()
+	if vertical {
+		thickness, length = length, thickness
+	}
+
+	// span returns the part of the rule that lies between the given offsets along and across it.
+	span := func(along0, along1, across0, across1 int) image.Rectangle {
+		if vertical {
+			return image.Rect(r.Min.X+across0, r.Min.Y+along0, r.Min.X+across1, r.Min.Y+along1)
+		}
+		return image.Rect(r.Min.X+along0, r.Min.Y+across0, r.Min.X+along1, r.Min.Y+across1)
+	}
+
+	switch style {
+	case RuleNone:
+	case RuleDashed, RuleDotted:
+		on, off := thickness*3, thickness*2
+		if style == RuleDotted {
+			on, off = thickness, thickness
+		}
+		for along := -(phase % (on + off)); along < length; along += on + off {
+			draw.Draw(dst, span(along, along+on, 0, thickness).Intersect(r), black, image.Point{}, draw.Src)
+		}
+	case RuleDouble:
+		if thickness >= 3 {
+			line := thickness / 3
+			draw.Draw(dst, span(0, length, 0, line), black, image.Point{}, draw.Src)
+			draw.Draw(dst, span(0, length, thickness-line, thickness), black, image.Point{}, draw.Src)
+			break
+		}
+		fallthrough
+	default:
+		draw.Draw(dst, r, black, image.Point{}, draw.Src)
+	}
+}
+
+// A vrule is a vertical rule drawn to the left of an indented block, such as a blockquote.
+type vrule struct {
+	x         float64 // The position of the rule's left edge in points.
+	thickness float64 // The thickness of the rule in points.
+	style     RuleStyle
+}
+
+// drawVRules draws the given vertical rules down the full height of the given image. y is the distance in dots from
+// the top of the output to the top of the image, which keeps dashed and dotted rules in phase across images.
+func drawVRules(dst draw.Image, vrules []vrule, dpi float64, y int) {
+	height := dst.Bounds().Dy()
+	for _, vr := range vrules {
+		x := int(math.Ceil(vr.x / 72.0 * dpi))
+		drawRule(dst, image.Rect(x, 0, x+ruleThickness(vr.thickness, dpi), height), vr.style, true, y)
+	}
+}
+
+// A trackingDevice records how far its output has advanced so that vertical rules, which are drawn into each bitmap
+// separately, can keep their patterns in phase from one bitmap to the next.
+type trackingDevice struct {
+	bitmap.Device
+	y int
+}
+
+func (d *trackingDevice) PrintBitmap(img *bitmap.Image) error {
+	d.y += img.Bounds().Dy()
+	return d.Device.PrintBitmap(img)
+}
+
+func (d *trackingDevice) Feed(lines int) error {
+	d.y += lines
+	return d.Device.Feed(lines)
+}
+
+func (d *trackingDevice) SupportsBarcode(b bitmap.Barcode) bool {
+	native, ok := d.Device.(bitmap.BarcodeDevice)
+	return ok && native.SupportsBarcode(b)
+}
+
+func (d *trackingDevice) PrintBarcode(b bitmap.Barcode) error {
+	d.y += b.Raster.Bounds().Dy()
+	return d.Device.(bitmap.BarcodeDevice).PrintBarcode(b)
+}
+
+// outputY returns the distance in dots from the top of the output to the next line printed to the given device, or
+// zero if the device does not track its output.
+func outputY(device bitmap.Device) int {
+	if d, ok := device.(*trackingDevice); ok {
+		return d.y
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package markdown
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestVRulePhase(t *testing.T) {
+	const height, split = 40, 13
+
+	for _, style := range []RuleStyle{RuleDashed, RuleDotted} {
+		// A rule drawn in two pieces, each offset by its distance from the top, matches a rule drawn in one.
+		whole := image.NewGray(image.Rect(0, 0, 2, height))
+		draw.Draw(whole, whole.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		drawRule(whole, image.Rect(0, 0, 2, height), style, true, 0)
+
+		top := image.NewGray(image.Rect(0, 0, 2, split))
+		draw.Draw(top, top.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		drawRule(top, image.Rect(0, 0, 2, split), style, true, 0)
+
+		bottom := image.NewGray(image.Rect(0, 0, 2, height-split))
+		draw.Draw(bottom, bottom.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		drawRule(bottom, image.Rect(0, 0, 2, height-split), style, true, split)
+
+		for y := 0; y < height; y++ {
+			expected, actual := whole.GrayAt(0, y), top.GrayAt(0, y)
+			if y >= split {
+				actual = bottom.GrayAt(0, y-split)
+			}
+			if expected != actual {
+				t.Errorf("%v: row %v: expected %v, got %v", style, y, expected.Y, actual.Y)
+			}
+		}
+	}
+}
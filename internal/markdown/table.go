@@ -0,0 +1,298 @@
+package markdown
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/pgavlin/goldmark/ast"
+	east "github.com/pgavlin/goldmark/extension/ast"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// cellPadding is the space between the contents of a table cell and the surrounding rules in points.
+const cellPadding = 2.0
+
+// canvas is an in-memory Device of a fixed width. Table cells are printed to canvases so that they can be placed
+// side by side.
+type canvas struct {
+	width   int
+	dpi     float64
+	bitmaps []*bitmap.Image
+	origins []int
+	height  int
+}
+
+func (c *canvas) MaxWidth() int {
+	return c.width
+}
+
+func (c *canvas) DPI() float64 {
+	return c.dpi
+}
+
+func (c *canvas) PrintBitmap(img *bitmap.Image) error {
+	c.bitmaps, c.origins = append(c.bitmaps, img), append(c.origins, c.height)
+	c.height += img.Bounds().Dy()
+	return nil
+}
+
+func (c *canvas) Feed(lines int) error {
+	c.height += lines
+	return nil
+}
+
+// drawTo draws the canvas' contents into dst with its upper-left corner at the given point.
+func (c *canvas) drawTo(dst draw.Image, at image.Point) {
+	for i, img := range c.bitmaps {
+		bounds := img.Bounds()
+		draw.Draw(dst, bounds.Sub(bounds.Min).Add(at.Add(image.Point{0, c.origins[i]})), img, bounds.Min, draw.Src)
+	}
+}
+
+// tableCell holds the contents of a table cell.
+type tableCell struct {
+	contents []content
+	align    Alignment
+}
+
+// measureCell returns the minimum and maximum widths of a cell's contents. The minimum width is the width of its
+// widest word; the maximum width is the width of its contents laid out on a single line.
+func measureCell(cell tableCell, dpi float64) (fixed.Int26_6, fixed.Int26_6) {
+	var minWidth, maxWidth fixed.Int26_6
+	for _, l := range layoutParagraph(fixed.I(1), dpi, cell.contents, false, 0, nil) {
+		if w := measureLine(l); w > minWidth {
+			minWidth = w
+		}
+	}
+	for _, l := range layoutParagraph(fixed.I(math.MaxInt32/64), dpi, cell.contents, false, 0, nil) {
+		if w := measureLine(l); w > maxWidth {
+			maxWidth = w
+		}
+	}
+	return minWidth, maxWidth
+}
+
+// collectTableCells renders the inline contents of each cell in the given table. Missing cells are left empty.
+func (r *Renderer) collectTableCells(device bitmap.Device, source []byte, node *east.Table) ([][]tableCell, error) {
+	paragraph, indentWidth := r.paragraph, r.indentWidth
+	r.paragraph, r.indentWidth = nil, 0
+	defer func() {
+		r.paragraph, r.indentWidth = paragraph, indentWidth
+	}()
+
+	var rows [][]tableCell
+	for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+		_, isHeader := row.(*east.TableHeader)
+
+		cells := make([]tableCell, len(node.Alignments))
+		column := 0
+		for cell := row.FirstChild(); cell != nil && column < len(cells); cell, column = cell.NextSibling(), column+1 {
+			face := r.proportionalFamily.Size(r.paragraphStyle.PointSize).Regular()
+			if isHeader {
+				face = face.WithBold(true)
+			}
+
+			r.pushFace(face)
+			err := r.walk(device, source, cell)
+			r.popFace()
+			if err != nil {
+				return nil, err
+			}
+
+			cells[column].contents, r.paragraph = r.paragraph, nil
+			switch node.Alignments[column] {
+			case east.AlignCenter:
+				cells[column].align = AlignCenter
+			case east.AlignRight:
+				cells[column].align = AlignRight
+			}
+		}
+		rows = append(rows, cells)
+	}
+	return rows, nil
+}
+
+// layoutTableColumns returns the width of each column of the given table, or false if the table does not fit in the
+// available width. Columns are given their maximum widths if possible. Otherwise, each column is given its minimum
+// width plus a share of the remaining space in proportion to the difference between its maximum and minimum widths.
+func layoutTableColumns(rows [][]tableCell, available int, dpi float64) ([]int, bool) {
+	if len(rows) == 0 {
+		return nil, true
+	}
+
+	columns := len(rows[0])
+	minWidths, maxWidths := make([]int, columns), make([]int, columns)
+	minTotal, maxTotal := 0, 0
+	for i := 0; i < columns; i++ {
+		for _, row := range rows {
+			minWidth, maxWidth := measureCell(row[i], dpi)
+			if minWidth.Ceil() > minWidths[i] {
+				minWidths[i] = minWidth.Ceil()
+			}
+			if maxWidth.Ceil() > maxWidths[i] {
+				maxWidths[i] = maxWidth.Ceil()
+			}
+		}
+		minTotal, maxTotal = minTotal+minWidths[i], maxTotal+maxWidths[i]
+	}
+
+	switch {
+	case maxTotal <= available:
+		return maxWidths, true
+	case minTotal > available:
+		return nil, false
+	}
+
+	widths := make([]int, columns)
+	for i := range widths {
+		widths[i] = minWidths[i] + (maxWidths[i]-minWidths[i])*(available-minTotal)/(maxTotal-minTotal)
+	}
+	return widths, true
+}
+
+// printTableRule prints a horizontal rule from x0 to x1 along with the current vertical rules.
+func (r *Renderer) printTableRule(device bitmap.Device, x0, x1 int) error {
+	img := bitmap.New(image.Rect(0, 0, device.MaxWidth(), 1))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	r.drawVRules(device, img)
+	draw.Draw(img, image.Rect(x0, 0, x1, 1), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	return device.PrintBitmap(img)
+}
+
+// drawVRules draws the current vertical rules into the given image, which is the next image to be printed to the given
+// device.
+func (r *Renderer) drawVRules(device bitmap.Device, img *bitmap.Image) {
+	drawVRules(img, r.vrules, device.DPI(), outputY(device))
+}
+
+// printTable prints a table whose columns have the given widths, with rules around and between each cell.
+func (r *Renderer) printTable(device bitmap.Device, rows [][]tableCell, widths []int) error {
+	dpi := device.DPI()
+	indent := int(math.Ceil(r.indentWidth / 72.0 * dpi))
+	padding := int(math.Ceil(cellPadding / 72.0 * dpi))
+
+	right := indent
+	for _, w := range widths {
+		right += 1 + w + 2*padding
+	}
+	right++
+
+	for _, row := range rows {
+		if err := r.printTableRule(device, indent, right); err != nil {
+			return err
+		}
+
+		// Print each cell to its own canvas.
+		canvases, height := make([]*canvas, len(row)), 0
+		for i, cell := range row {
+			canvases[i] = &canvas{width: widths[i], dpi: dpi}
+			if err := printParagraph(canvases[i], cell.contents, false, BlockStyle{Align: cell.align}, r.hyphenation); err != nil {
+				return err
+			}
+			if canvases[i].height > height {
+				height = canvases[i].height
+			}
+		}
+		height += 2 * padding
+
+		// Place the cells side by side, separated by rules.
+		img := bitmap.New(image.Rect(0, 0, device.MaxWidth(), height))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		r.drawVRules(device, img)
+
+		x := indent
+		for i, c := range canvases {
+			draw.Draw(img, image.Rect(x, 0, x+1, height), image.NewUniform(color.Black), image.Point{}, draw.Src)
+			c.drawTo(img, image.Point{x + 1 + padding, padding})
+			x += 1 + widths[i] + 2*padding
+		}
+		draw.Draw(img, image.Rect(x, 0, x+1, height), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+		if err := device.PrintBitmap(img); err != nil {
+			return err
+		}
+	}
+	return r.printTableRule(device, indent, right)
+}
+
+// printStackedTable prints each row of a table that is too wide for the paper as a list of "header: value" lines,
+// with rules between the rows. A table without any body rows is printed as a list of its header cells.
+func (r *Renderer) printStackedTable(device bitmap.Device, rows [][]tableCell) error {
+	dpi := device.DPI()
+	indent := int(math.Ceil(r.indentWidth / 72.0 * dpi))
+	bold := r.proportionalFamily.Size(r.paragraphStyle.PointSize).Bold()
+
+	header, body := rows[0], rows[1:]
+	if len(body) == 0 {
+		for _, cell := range header {
+			r.appendContent(cell.contents...)
+			if err := r.printParagraph(device, BlockStyle{}, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, row := range body {
+		if i > 0 {
+			if err := r.printTableRule(device, indent, device.MaxWidth()); err != nil {
+				return err
+			}
+		}
+
+		for column, cell := range row {
+			r.appendContent(header[column].contents...)
+			r.appendContent(text{face: bold, bytes: []byte(": ")})
+			r.appendContent(cell.contents...)
+			if err := r.printParagraph(device, BlockStyle{}, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderTable renders an *east.Table node to the given Device.
+func (r *Renderer) renderTable(device bitmap.Device, source []byte, node *east.Table, enter bool) (ast.WalkStatus, error) {
+	if !enter {
+		return ast.WalkContinue, nil
+	}
+
+	rows, err := r.collectTableCells(device, source, node)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	if len(rows) == 0 {
+		return ast.WalkSkipChildren, nil
+	}
+
+	if err := r.printMargin(device, r.paragraphStyle.TopMargin); err != nil {
+		return ast.WalkStop, err
+	}
+	if err := r.flushParagraph(device); err != nil {
+		return ast.WalkStop, err
+	}
+
+	// Each column is surrounded by padding and shares its rules with its neighbors.
+	dpi := device.DPI()
+	columns := len(node.Alignments)
+	available := device.MaxWidth() - int(math.Ceil(r.indentWidth/72.0*dpi)) - (columns + 1) -
+		columns*2*int(math.Ceil(cellPadding/72.0*dpi))
+	if widths, ok := layoutTableColumns(rows, available, dpi); ok {
+		err = r.printTable(device, rows, widths)
+	} else {
+		err = r.printStackedTable(device, rows)
+	}
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	if err := r.printMargin(device, r.paragraphStyle.BottomMargin); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,54 @@
+package markdown
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/pgavlin/goldmark/ast"
+	east "github.com/pgavlin/goldmark/extension/ast"
+	"golang.org/x/image/font"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// taskCheckBox returns the checkbox of the given list item, or nil if the item is not a task.
+func taskCheckBox(item ast.Node) *east.TaskCheckBox {
+	if block := item.FirstChild(); block != nil {
+		if box, ok := block.FirstChild().(*east.TaskCheckBox); ok {
+			return box
+		}
+	}
+	return nil
+}
+
+// checkBox draws a checkbox sized to match the capital letters of the given face. Checked boxes contain a check mark.
+func checkBox(face font.Face, checked bool) *bitmap.Image {
+	size := (face.Metrics().Ascent * 3 / 4).Ceil()
+	thickness := size / 10
+	if thickness < 1 {
+		thickness = 1
+	}
+
+	img := bitmap.New(image.Rect(0, 0, size, size))
+	black, white := image.NewUniform(color.Black), image.NewUniform(color.White)
+	draw.Draw(img, img.Bounds(), black, image.Point{}, draw.Src)
+	draw.Draw(img, img.Bounds().Inset(thickness), white, image.Point{}, draw.Src)
+
+	if checked {
+		// Draw the check mark as two strokes: a short one down to the bottom of the mark, and a long one up to the
+		// right.
+		stroke := func(x0, y0, x1, y1 float64) {
+			steps := size * 2
+			for i := 0; i <= steps; i++ {
+				t := float64(i) / float64(steps)
+				x, y := int(x0+(x1-x0)*t), int(y0+(y1-y0)*t)
+				draw.Draw(img, image.Rect(x, y, x+thickness+1, y+thickness+1), black, image.Point{}, draw.Src)
+			}
+		}
+		s := float64(size)
+		stroke(s*0.22, s*0.50, s*0.42, s*0.70)
+		stroke(s*0.42, s*0.70, s*0.78-float64(thickness), s*0.25)
+	}
+	return img
+}
@@ -2,11 +2,16 @@ package markdown
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"io/ioutil"
 	"math"
+	"net/url"
+	"path/filepath"
+	"strings"
 
 	"golang.org/x/image/math/fixed"
 
@@ -25,12 +30,69 @@ func floatToFixed(f float64) fixed.Int26_6 {
 	return fixed.Int26_6(int(integer)*64 | int(math.Trunc(frac*64.0))&63)
 }
 
-func downloadImage(url string) (image.Image, error) {
-	contents, _, err := util.DownloadFile(url)
-	if err != nil {
-		return nil, err
+// loadImage loads and decodes the image at the given location. Images may always be downloaded over HTTP or HTTPS.
+// Local files, given as paths or file:// URLs, may only be loaded if baseDir is set, and then only from within
+// baseDir; this keeps documents submitted to a server from reading the server's files.
+func loadImage(baseDir, location string) (image.Image, error) {
+	var contents []byte
+	if u, err := url.Parse(location); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if contents, _, err = util.DownloadFile(location); err != nil {
+			return nil, err
+		}
+	} else {
+		path, err := localImagePath(baseDir, location)
+		if err != nil {
+			return nil, err
+		}
+		if contents, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
 	}
 
 	img, _, err := image.Decode(bytes.NewReader(contents))
 	return img, err
 }
+
+// localImagePath returns the path of the local image at the given location. Relative paths are resolved against the
+// given base directory. It is an error if baseDir is empty, if the location is a URL with a scheme other than file,
+// or if the resolved path lies outside of baseDir.
+func localImagePath(baseDir, location string) (string, error) {
+	if baseDir == "" {
+		return "", fmt.Errorf("local images are not allowed: '%v'", location)
+	}
+
+	// Windows drive letters parse as single-letter schemes, so treat those as paths as well.
+	path := location
+	if u, err := url.Parse(location); err == nil && len(u.Scheme) > 1 {
+		if u.Scheme != "file" {
+			return "", fmt.Errorf("unsupported image URL scheme '%v'", u.Scheme)
+		}
+		path = filepath.FromSlash(u.Path)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+
+	// Resolve symbolic links so that a link inside baseDir cannot point outside of it.
+	base, err := canonicalPath(baseDir)
+	if err != nil {
+		return "", err
+	}
+	if path, err = canonicalPath(path); err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("image '%v' is outside of '%v'", location, baseDir)
+	}
+	return path, nil
+}
+
+// canonicalPath returns the absolute form of the given path with all symbolic links resolved.
+func canonicalPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
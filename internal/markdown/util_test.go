@@ -0,0 +1,65 @@
+package markdown
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalImagePath(t *testing.T) {
+	root, err := ioutil.TempDir("", "images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(root, "doc")
+	for _, path := range []string{filepath.Join(base, "image.png"), filepath.Join(root, "secret.png")} {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Symlink(filepath.Join(root, "secret.png"), filepath.Join(base, "link.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	inside := filepath.Join(base, "image.png")
+	cases := []struct {
+		name, baseDir, location, expected string
+	}{
+		{name: "relative", baseDir: base, location: "image.png", expected: inside},
+		{name: "dot segments", baseDir: base, location: "./sub/../image.png", expected: inside},
+		{name: "absolute", baseDir: base, location: inside, expected: inside},
+		{name: "file URL", baseDir: base, location: "file://" + filepath.ToSlash(inside), expected: inside},
+
+		{name: "no base directory", location: "image.png"},
+		{name: "no base directory absolute", location: inside},
+		{name: "no base directory file URL", location: "file://" + filepath.ToSlash(inside)},
+		{name: "parent", baseDir: base, location: "../secret.png"},
+		{name: "absolute outside", baseDir: base, location: filepath.Join(root, "secret.png")},
+		{name: "file URL outside", baseDir: base, location: "file://" + filepath.ToSlash(filepath.Join(root, "secret.png"))},
+		{name: "symbolic link outside", baseDir: base, location: "link.png"},
+		{name: "other scheme", baseDir: base, location: "ftp://example.com/image.png"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := localImagePath(c.baseDir, c.location)
+			switch {
+			case c.expected == "" && err == nil:
+				t.Fatalf("expected an error, got %q", actual)
+			case c.expected != "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case actual != c.expected:
+				t.Fatalf("expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package printer
+
+import (
+	"image"
+	"image/draw"
+	"time"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// barcodeSystems maps each natively-supported symbology to its GS k barcode system.
+var barcodeSystems = map[string]byte{
+	"upca":    65,
+	"ean13":   67,
+	"code128": 73,
+}
+
+// code128Modules is the width of each Code 128 symbol in modules. The stop symbol is two modules wider.
+const code128Modules = 11
+
+// useCodeSetC returns true if the given Code 128 data should continue in code set C, which encodes pairs of digits as
+// single symbols. This matches the choice made by the raster encoder: a run of digits switches to code set C if it is
+// at least four digits long, or two digits long if code set C is already in use.
+func useCodeSetC(data string, inC bool) bool {
+	required := 4
+	if inC {
+		required = 2
+	}
+	if len(data) < required {
+		return false
+	}
+	for i := 0; i < required; i++ {
+		if data[i] < '0' || data[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// barcodeData returns the GS k data for the given barcode along with the barcode's width in modules, excluding its
+// quiet zones.
+func barcodeData(b bitmap.Barcode) ([]byte, int) {
+	if b.Symbology != "code128" {
+		return []byte(b.Data), b.Modules
+	}
+
+	// Code 128 data begins with a code set selector, which is encoded as the start symbol. Later selectors switch code
+	// sets. In code set B, a literal '{' is written as "{{"; in code set C, each byte holds a pair of digits.
+	var data []byte
+	symbols, codeSet := 0, byte(0)
+	for i := 0; i < len(b.Data); i, symbols = i+1, symbols+1 {
+		next := byte('B')
+		if useCodeSetC(b.Data[i:], codeSet == 'C') {
+			next = 'C'
+		}
+		if next != codeSet {
+			data, symbols, codeSet = append(data, '{', next), symbols+1, next
+		}
+
+		switch {
+		case codeSet == 'C':
+			data = append(data, (b.Data[i]-'0')*10+b.Data[i+1]-'0')
+			i++
+		case b.Data[i] == '{':
+			data = append(data, '{', '{')
+		default:
+			data = append(data, b.Data[i])
+		}
+	}
+
+	// The data is followed by a check symbol and a stop symbol.
+	return data, (symbols+1)*code128Modules + code128Modules + 2
+}
+
+// SupportsBarcode returns true if the printer can print the given barcode using its GS k command.
+func (d *Device) SupportsBarcode(b bitmap.Barcode) bool {
+	if _, ok := barcodeSystems[b.Symbology]; !ok {
+		return false
+	}
+	for i := 0; i < len(b.Data); i++ {
+		if b.Data[i] < 0x20 || b.Data[i] > 0x7e {
+			return false
+		}
+	}
+	if b.PrintText && (b.Raster == nil || b.Raster.Bounds().Dy() <= b.Height) {
+		return false
+	}
+
+	// The printer centers barcodes across the entire print head, which only matches the printable area if the
+	// margins are equal.
+	data, modules := barcodeData(b)
+	profile := d.config.Profile
+	return profile.LeftMargin == profile.RightMargin &&
+		b.ModuleWidth >= 2 && b.ModuleWidth <= 6 &&
+		b.Height >= 1 && b.Height <= 255 &&
+		modules*b.ModuleWidth <= d.MaxWidth() &&
+		len(data) <= 255
+}
+
+// PrintBarcode prints the given barcode using the printer's GS k command. The printer's own human-readable text uses a
+// different font and height than the barcode's raster form, so if the barcode's contents should be printed, they are
+// printed from the caption beneath the bars in the raster form instead.
+func (d *Device) PrintBarcode(b bitmap.Barcode) error {
+	data, _ := barcodeData(b)
+
+	cmd := []byte{
+		0x1b, 0x61, 1, // ESC a n selects center justification
+		0x1d, 0x68, byte(b.Height), // GS h n sets the bar height
+		0x1d, 0x77, byte(b.ModuleWidth), // GS w n sets the module width
+		0x1d, 0x48, 0, // GS H n disables the human-readable text
+		0x1d, 0x6b, barcodeSystems[b.Symbology], byte(len(data)), // GS k m n d1...dn prints the barcode
+	}
+	cmd = append(cmd, data...)
+	cmd = append(cmd, 0x1b, 0x61, 0) // ESC a n restores left justification
+	if err := d.write(cmd, time.Duration(b.Height)*d.config.DotPrintTime); err != nil {
+		return d.writeError(err)
+	}
+
+	if !b.PrintText {
+		return nil
+	}
+	bounds := b.Raster.Bounds()
+	caption := bitmap.New(image.Rect(0, 0, bounds.Dx(), bounds.Dy()-b.Height))
+	draw.Draw(caption, caption.Bounds(), b.Raster, image.Point{bounds.Min.X, bounds.Min.Y + b.Height}, draw.Src)
+	return d.PrintBitmap(caption)
+}
@@ -0,0 +1,137 @@
+package printer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/boombuler/barcode/code128"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+func TestBarcodeData(t *testing.T) {
+	cases := []struct {
+		name     string
+		barcode  bitmap.Barcode
+		expected string
+	}{
+		{name: "ean13", barcode: bitmap.Barcode{Symbology: "ean13", Data: "4006381333931", Modules: 95}, expected: "4006381333931"},
+		{name: "upca", barcode: bitmap.Barcode{Symbology: "upca", Data: "036000291452", Modules: 95}, expected: "036000291452"},
+
+		{name: "text", barcode: bitmap.Barcode{Symbology: "code128", Data: "SHELF-A12"}, expected: "{BSHELF-A12"},
+		{name: "brace", barcode: bitmap.Barcode{Symbology: "code128", Data: "a{b"}, expected: "{Ba{{b"},
+		{name: "short digits", barcode: bitmap.Barcode{Symbology: "code128", Data: "123"}, expected: "{B123"},
+		{name: "digits", barcode: bitmap.Barcode{Symbology: "code128", Data: "12345678"}, expected: "{C\x0c\x22\x38\x4e"},
+		{name: "odd digits", barcode: bitmap.Barcode{Symbology: "code128", Data: "12345"}, expected: "{C\x0c\x22{B5"},
+		{name: "text then digits", barcode: bitmap.Barcode{Symbology: "code128", Data: "AB1234"}, expected: "{BAB{C\x0c\x22"},
+		{name: "digits in text", barcode: bitmap.Barcode{Symbology: "code128", Data: "A00B12C"}, expected: "{BA00B12C"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, modules := barcodeData(c.barcode)
+			if string(data) != c.expected {
+				t.Errorf("expected data %q, got %q", c.expected, data)
+			}
+
+			// The module count must match the raster encoder, which makes the same code set choices.
+			expectedModules := c.barcode.Modules
+			if c.barcode.Symbology == "code128" {
+				code, err := code128.Encode(c.barcode.Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expectedModules = code.Bounds().Dx()
+			}
+			if modules != expectedModules {
+				t.Errorf("expected %v modules, got %v", expectedModules, modules)
+			}
+		})
+	}
+}
+
+// newCode128 returns a Code 128 barcode request for the given data with the given module width.
+func newCode128(data string, moduleWidth int) bitmap.Barcode {
+	return bitmap.Barcode{Symbology: "code128", Data: data, ModuleWidth: moduleWidth, Height: 48}
+}
+
+func TestSupportsBarcode(t *testing.T) {
+	// Twenty digits are 255 modules wide in code set B, which does not fit on a 384-dot print head at two dots per
+	// module, but only 145 modules wide in code set C.
+	digits := "12345678901234567890"
+
+	cases := []struct {
+		name     string
+		setup    func(b *bitmap.Barcode, config *Config)
+		expected bool
+	}{
+		{name: "supported", expected: true},
+		{name: "unsupported symbology", setup: func(b *bitmap.Barcode, config *Config) { b.Symbology = "qr" }},
+		{name: "control character", setup: func(b *bitmap.Barcode, config *Config) { b.Data = "A\tB" }},
+		{name: "non-ASCII", setup: func(b *bitmap.Barcode, config *Config) { b.Data = "café" }},
+		{name: "unequal margins", setup: func(b *bitmap.Barcode, config *Config) { config.Profile.LeftMargin = 8 }},
+		{name: "equal margins", setup: func(b *bitmap.Barcode, config *Config) {
+			config.Profile.LeftMargin, config.Profile.RightMargin = 8, 8
+		}, expected: true},
+		{name: "narrow modules", setup: func(b *bitmap.Barcode, config *Config) { b.ModuleWidth = 1 }},
+		{name: "wide modules", setup: func(b *bitmap.Barcode, config *Config) { b.ModuleWidth = 7 }},
+		{name: "no height", setup: func(b *bitmap.Barcode, config *Config) { b.Height = 0 }},
+		{name: "too tall", setup: func(b *bitmap.Barcode, config *Config) { b.Height = 256 }},
+		{name: "numeric", setup: func(b *bitmap.Barcode, config *Config) { b.Data = digits }, expected: true},
+		{name: "too wide", setup: func(b *bitmap.Barcode, config *Config) { b.Data = "ABCDEFGHIJKLMNOPQRST" }},
+		{name: "text without raster", setup: func(b *bitmap.Barcode, config *Config) { b.PrintText = true }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b, config := newCode128("SHELF-A12", 2), DefaultConfig
+			if c.setup != nil {
+				c.setup(&b, &config)
+			}
+			if actual := New(&bytes.Buffer{}, config).SupportsBarcode(b); actual != c.expected {
+				t.Fatalf("expected %v, got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPrintBarcode(t *testing.T) {
+	header := func(height, moduleWidth byte, system byte, data string) []byte {
+		cmd := []byte{0x1b, 0x61, 1, 0x1d, 0x68, height, 0x1d, 0x77, moduleWidth, 0x1d, 0x48, 0, 0x1d, 0x6b, system,
+			byte(len(data))}
+		return append(append(cmd, data...), 0x1b, 0x61, 0)
+	}
+
+	t.Run("without text", func(t *testing.T) {
+		serial := newFakeSerial()
+		if err := newFakeDevice(serial).PrintBarcode(newCode128("1234", 3)); err != nil {
+			t.Fatal(err)
+		}
+		if expected := header(48, 3, 73, "{C\x0c\x22"); !bytes.Equal(serial.written.Bytes(), expected) {
+			t.Fatalf("expected % x, got % x", expected, serial.written.Bytes())
+		}
+	})
+
+	t.Run("with text", func(t *testing.T) {
+		// The raster holds the bars followed by a two-line caption whose first dot is black.
+		b := bitmap.Barcode{Symbology: "ean13", Data: "4006381333931", Modules: 95, ModuleWidth: 2, Height: 4,
+			PrintText: true}
+		b.Raster = bitmap.New(image.Rect(0, 0, 16, 6))
+		draw.Draw(b.Raster, b.Raster.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		b.Raster.SetBit(0, 4, false)
+
+		serial := newFakeSerial()
+		if err := newFakeDevice(serial).PrintBarcode(b); err != nil {
+			t.Fatal(err)
+		}
+
+		// The printer's own text is disabled, and the caption follows as two DC2 * raster rows.
+		expected := header(4, 2, 67, "4006381333931")
+		expected = append(expected, 0x12, 0x2a, 1, 2, 0x80, 0x00)
+		expected = append(expected, 0x12, 0x2a, 1, 2, 0x00, 0x00)
+		if !bytes.Equal(serial.written.Bytes(), expected) {
+			t.Fatalf("expected % x, got % x", expected, serial.written.Bytes())
+		}
+	})
+}
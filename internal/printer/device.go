@@ -3,39 +3,176 @@ package printer
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/pgavlin/lilprinty/internal/bitmap"
 )
 
+// Config describes the speed of the printer and of the link to it and the heating parameters of its print head.
+//
+// Writes to the printer are paced using the speed figures so that large images do not overflow the printer's input
+// buffer. The heating parameters are sent to the printer by Initialize. More heating produces darker output at the
+// cost of print speed and power draw.
+type Config struct {
+	// Profile describes the geometry of the printer's print head.
+	Profile Profile
+
+	// BaudRate is the speed of the link to the printer in bits per second. If zero, the link is assumed to be
+	// instantaneous.
+	BaudRate int
+	// BitsPerByte is the number of bits sent over the link for each byte of data, including the start, parity, and stop
+	// bits. If zero, the link is assumed to send one start bit, eight data bits, and one stop bit.
+	BitsPerByte float64
+	// DotPrintTime is the time the printer takes to print a single line of dots.
+	DotPrintTime time.Duration
+	// DotFeedTime is the time the printer takes to feed the paper by a single line of dots.
+	DotFeedTime time.Duration
+
+	// HeatingDots is the maximum number of dots heated at once in units of 8 dots, less one. Must be in [0, 255].
+	HeatingDots int
+	// HeatTime is the time each dot is heated in units of 10µs. Must be in [3, 255].
+	HeatTime int
+	// HeatInterval is the time between heating successive groups of dots in units of 10µs. Must be in [0, 255].
+	HeatInterval int
+	// PrintDensity is the print density as a 5% increment over 50%. Must be in [0, 31].
+	PrintDensity int
+	// BreakTime is the print break time in units of 250µs. Must be in [0, 7].
+	BreakTime int
+}
+
+// DefaultConfig is a conservative configuration for a 9600-baud thermal printer.
+var DefaultConfig = Config{
+	Profile:      Profile58mm,
+	BaudRate:     9600,
+	DotPrintTime: 30 * time.Millisecond,
+	DotFeedTime:  2100 * time.Microsecond,
+	HeatingDots:  11,
+	HeatTime:     120,
+	HeatInterval: 40,
+	PrintDensity: 10,
+	BreakTime:    2,
+}
+
+// Validate returns an error if the config's profile is unusable or any of its heating parameters are out of range.
+func (c Config) Validate() error {
+	if err := c.Profile.Validate(); err != nil {
+		return err
+	}
+
+	check := func(name string, value, min, max int) error {
+		if value < min || value > max {
+			return fmt.Errorf("%v must be in the range [%d, %d]", name, min, max)
+		}
+		return nil
+	}
+
+	if err := check("heating dots", c.HeatingDots, 0, 255); err != nil {
+		return err
+	}
+	if err := check("heat time", c.HeatTime, 3, 255); err != nil {
+		return err
+	}
+	if err := check("heat interval", c.HeatInterval, 0, 255); err != nil {
+		return err
+	}
+	if err := check("print density", c.PrintDensity, 0, 31); err != nil {
+		return err
+	}
+	return check("break time", c.BreakTime, 0, 7)
+}
+
 type Device struct {
-	w io.Writer
+	w      io.Writer
+	r      io.Reader
+	config Config
+	pacer  *pacer
+}
+
+func New(w io.Writer, config Config) *Device {
+	return &Device{w: w, config: config, pacer: newPacer(config.BaudRate, config.BitsPerByte)}
 }
 
-func New(w io.Writer) *Device {
-	return &Device{w: w}
+// NewReadWriter creates a device that can query the printer's status over rw. See CheckStatus. Reads from rw should time
+// out rather than block forever so that a disconnected printer can be detected.
+func NewReadWriter(rw io.ReadWriter, config Config) *Device {
+	d := New(rw, config)
+	d.r = rw
+	return d
+}
+
+// Initialize resets the printer and sends the device's heating parameters.
+func (d *Device) Initialize() error {
+	if err := d.config.Validate(); err != nil {
+		return err
+	}
+	return d.initialize(d.write)
+}
+
+// Reinitialize resets the printer and sends the device's heating parameters over w, a freshly reopened connection to
+// the printer. It is intended for use as a transport.Conn's OnReconnect hook: the printer may have reset while the
+// connection was down, so the settings sent by Initialize must be sent again before printing continues.
+func (d *Device) Reinitialize(w io.Writer) error {
+	return d.initialize(func(b []byte, work time.Duration) error {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		d.pacer.sleep(work)
+		return nil
+	})
+}
+
+func (d *Device) initialize(write func(b []byte, work time.Duration) error) error {
+	// ESC @ resets the printer to its power-on state. Give it a moment to recover before sending anything else.
+	if err := write([]byte{0x1b, 0x40}, 50*time.Millisecond); err != nil {
+		return err
+	}
+
+	// ESC 7 n1 n2 n3 sets the heating dots, heat time, and heat interval.
+	heat := []byte{0x1b, 0x37, byte(d.config.HeatingDots), byte(d.config.HeatTime), byte(d.config.HeatInterval)}
+	if err := write(heat, 0); err != nil {
+		return err
+	}
+
+	// DC2 # n sets the print density in bits 0-4 and the break time in bits 5-7.
+	return write([]byte{0x12, 0x23, byte(d.config.BreakTime<<5 | d.config.PrintDensity)}, 0)
+}
+
+// write writes b to the printer once it is ready to receive it. work is the time the printer needs to process b once
+// it has been received. b must hold whole commands, as a failed write may be retried in full after reconnecting.
+func (d *Device) write(b []byte, work time.Duration) error {
+	d.pacer.wait()
+	n, err := d.w.Write(b)
+	d.pacer.advance(n, work)
+	return err
+}
+
+// Profile returns the profile of the device's print head.
+func (d *Device) Profile() Profile {
+	return d.config.Profile
 }
 
 func (d *Device) MaxWidth() int {
-	return 384
+	return d.config.Profile.PrintableWidth()
 }
 
 func (d *Device) DPI() float64 {
-	return 203.2
+	return d.config.Profile.DPI
 }
 
 func (d *Device) PrintBitmap(img *bitmap.Image) error {
-	if img.Bounds().Dx() > 384 {
-		return fmt.Errorf("bitmap must be less than 384 pixels wide")
+	if img.Bounds().Dx() > d.MaxWidth() {
+		return fmt.Errorf("bitmap must be less than %d pixels wide", d.MaxWidth())
 	}
 
-	// Print the image one scanline at a time.
-	row := make([]byte, 4+img.Bounds().Dx()/8)
+	// Print the image one scanline at a time. Each scanline begins with the profile's left margin.
+	margin := d.config.Profile.LeftMargin
+	row := make([]byte, 4+(margin+img.Bounds().Dx()+7)/8)
 	row[0], row[1], row[2], row[3] = 0x12, 0x2A, 1, byte(len(row)-4)
 
 	for y := 0; y < img.Bounds().Dy(); y++ {
 		// Render the row.
 		for x := 0; x < img.Bounds().Dx(); x++ {
-			byteOffset, bitOffset := 4+x/8, 7-x%8
+			byteOffset, bitOffset := 4+(margin+x)/8, 7-(margin+x)%8
 
 			// A set bit in the bitmap corresponds to the color white, but a set bit in the output corresponds to the
 			// color black, so we need to invert the bits when rendering the row.
@@ -47,8 +184,8 @@ func (d *Device) PrintBitmap(img *bitmap.Image) error {
 		}
 
 		// Write the row.
-		if _, err := d.w.Write(row); err != nil {
-			return err
+		if err := d.write(row, d.config.DotPrintTime); err != nil {
+			return d.writeError(err)
 		}
 	}
 	return nil
@@ -58,6 +195,8 @@ func (p *Device) Feed(lines int) error {
 	if lines < 0 || lines >= 256 {
 		return fmt.Errorf("lines must be in the range [0, 256)")
 	}
-	_, err := p.w.Write([]byte{0x1b, 0x4a, byte(lines)})
-	return err
+	if err := p.write([]byte{0x1b, 0x4a, byte(lines)}, time.Duration(lines)*p.config.DotFeedTime); err != nil {
+		return p.writeError(err)
+	}
+	return nil
 }
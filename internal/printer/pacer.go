@@ -0,0 +1,43 @@
+package printer
+
+import "time"
+
+// A pacer throttles writes to the printer so that they do not outrun its input buffer. Each write pushes back the time
+// at which the next write may begin by the time the printer needs to receive and print the written data.
+type pacer struct {
+	byteTime time.Duration
+	resume   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// newPacer creates a pacer for a link that sends the given number of bits per byte of data at the given baud rate. If
+// bitsPerByte is zero, the link is assumed to send one start bit, eight data bits, and one stop bit.
+func newPacer(baudRate int, bitsPerByte float64) *pacer {
+	p := &pacer{now: time.Now, sleep: time.Sleep}
+	if bitsPerByte == 0 {
+		bitsPerByte = 10
+	}
+	if baudRate > 0 {
+		p.byteTime = time.Duration(bitsPerByte * float64(time.Second) / float64(baudRate))
+	}
+	return p
+}
+
+// wait blocks until the printer is ready to receive more data.
+func (p *pacer) wait() {
+	if d := p.resume.Sub(p.now()); d > 0 {
+		p.sleep(d)
+	}
+}
+
+// advance records that n bytes were just written and that the printer needs the given additional time to process
+// them.
+func (p *pacer) advance(n int, work time.Duration) {
+	now := p.now()
+	if p.resume.Before(now) {
+		p.resume = now
+	}
+	p.resume = p.resume.Add(time.Duration(n)*p.byteTime + work)
+}
@@ -0,0 +1,104 @@
+package printer
+
+import (
+	"bytes"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// fakeClock stands in for the pacer's now and sleep hooks. Time only passes when the pacer sleeps or the link is busy.
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+	c.slept += d
+}
+
+// slowWriter simulates a link that takes the given time to accept each write, regardless of its size.
+type slowWriter struct {
+	bytes.Buffer
+	clock *fakeClock
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(b []byte) (int, error) {
+	w.clock.now = w.clock.now.Add(w.delay)
+	return w.Buffer.Write(b)
+}
+
+func newPacedDevice(config Config, delay time.Duration) (*Device, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	device := New(&slowWriter{clock: clock, delay: delay}, config)
+	device.pacer.now = func() time.Time { return clock.now }
+	device.pacer.sleep = clock.sleep
+	return device, clock
+}
+
+func TestPacerByteTime(t *testing.T) {
+	cases := []struct {
+		baudRate    int
+		bitsPerByte float64
+		expected    time.Duration
+	}{
+		{baudRate: 0, bitsPerByte: 10, expected: 0},
+		{baudRate: 9600, bitsPerByte: 0, expected: 1041666 * time.Nanosecond},
+		{baudRate: 9600, bitsPerByte: 10, expected: 1041666 * time.Nanosecond},
+		{baudRate: 9600, bitsPerByte: 12, expected: 1250 * time.Microsecond},
+		{baudRate: 19200, bitsPerByte: 7.5, expected: 390625 * time.Nanosecond},
+	}
+	for _, c := range cases {
+		if actual := newPacer(c.baudRate, c.bitsPerByte).byteTime; actual != c.expected {
+			t.Errorf("%v baud, %v bits: expected %v, got %v", c.baudRate, c.bitsPerByte, c.expected, actual)
+		}
+	}
+}
+
+func TestPacerWaitsForPrinter(t *testing.T) {
+	config := DefaultConfig
+	config.BaudRate, config.BitsPerByte = 9600, 12
+
+	// The link takes 5ms to accept each write. The printer only starts receiving a write once the link has accepted
+	// it, so the time spent in the write does not count towards the pacing.
+	delay := 5 * time.Millisecond
+	device, clock := newPacedDevice(config, delay)
+
+	// Each row of a 384-dot-wide bitmap is a 4-byte header plus 48 bytes of dots: 52 bytes at 1.25ms per byte takes
+	// 65ms to send, and the printer needs another 30ms to print it.
+	img := bitmap.New(image.Rect(0, 0, 384, 10))
+	if err := device.PrintBitmap(img); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pacer sleeps before every row but the first.
+	rowTime := 52*1250*time.Microsecond + config.DotPrintTime
+	if expected := 9 * rowTime; clock.slept != expected {
+		t.Fatalf("expected to sleep for %v, got %v", expected, clock.slept)
+	}
+	if expected := time.Unix(0, 0).Add(10*delay + 10*rowTime); !device.pacer.resume.Equal(expected) {
+		t.Fatalf("expected to resume at %v, got %v", expected, device.pacer.resume)
+	}
+}
+
+func TestPacerDoesNotWaitForSlowCaller(t *testing.T) {
+	config := DefaultConfig
+	config.BaudRate = 9600
+	device, clock := newPacedDevice(config, 0)
+
+	// A caller that takes longer to produce each write than the printer takes to receive and process it needs no
+	// pacing.
+	for i := 0; i < 10; i++ {
+		if err := device.Feed(10); err != nil {
+			t.Fatal(err)
+		}
+		clock.now = clock.now.Add(time.Second)
+	}
+	if clock.slept != 0 {
+		t.Fatalf("expected not to sleep, slept for %v", clock.slept)
+	}
+}
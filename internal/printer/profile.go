@@ -0,0 +1,48 @@
+package printer
+
+import "fmt"
+
+// A Profile describes the geometry of a printer's print head.
+type Profile struct {
+	// Width is the number of dots across the print head.
+	Width int `json:"width"`
+	// DPI is the resolution of the print head in dots per inch.
+	DPI float64 `json:"dpi"`
+	// LeftMargin is the number of dots at the left edge of the print head that are left blank.
+	LeftMargin int `json:"leftMargin,omitempty"`
+	// RightMargin is the number of dots at the right edge of the print head that are left blank.
+	RightMargin int `json:"rightMargin,omitempty"`
+}
+
+var (
+	// Profile58mm describes the 384-dot print head common to 58mm receipt printers.
+	Profile58mm = Profile{Width: 384, DPI: 203.2}
+	// Profile80mm describes the 576-dot print head common to 80mm receipt printers.
+	Profile80mm = Profile{Width: 576, DPI: 203.2}
+)
+
+// Profiles maps the names of well-known profiles to their definitions.
+var Profiles = map[string]Profile{
+	"58mm": Profile58mm,
+	"80mm": Profile80mm,
+}
+
+// PrintableWidth returns the width of the area between the profile's margins in dots.
+func (p Profile) PrintableWidth() int {
+	return p.Width - p.LeftMargin - p.RightMargin
+}
+
+// Validate returns an error if the profile does not describe a usable print head.
+func (p Profile) Validate() error {
+	switch {
+	case p.Width <= 0 || p.Width > 255*8:
+		return fmt.Errorf("width must be in the range [1, %d]", 255*8)
+	case p.DPI <= 0:
+		return fmt.Errorf("DPI must be positive")
+	case p.LeftMargin < 0 || p.RightMargin < 0:
+		return fmt.Errorf("margins must not be negative")
+	case p.PrintableWidth() <= 0:
+		return fmt.Errorf("margins must leave a printable area")
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrStatusUnsupported is returned by Status if the device was not created with a readable link to the printer.
+	ErrStatusUnsupported = errors.New("printer status is not available over a write-only link")
+	// ErrNoResponse is returned if the printer does not answer a status query, e.g. because it has been disconnected.
+	ErrNoResponse = errors.New("printer did not respond to a status query")
+	// ErrOffline is returned if the printer reports that it is offline.
+	ErrOffline = errors.New("printer is offline")
+	// ErrPaperOut is returned if the printer's paper sensor reports that the paper has run out.
+	ErrPaperOut = errors.New("printer is out of paper")
+	// ErrOverheated is returned if the printer reports a recoverable error, which thermal printers raise when their
+	// print head is too hot.
+	ErrOverheated = errors.New("printer head is overheated")
+)
+
+// Status describes the state of the printer as reported by its real-time status commands.
+type Status struct {
+	Online        bool `json:"online"`
+	PaperOut      bool `json:"paperOut"`
+	PaperLow      bool `json:"paperLow"`
+	Overheated    bool `json:"overheated"`
+	Unrecoverable bool `json:"unrecoverable"`
+}
+
+// Err returns the error that corresponds to the most severe condition described by the status, if any.
+func (s Status) Err() error {
+	var err error
+	switch {
+	case s.PaperOut:
+		err = ErrPaperOut
+	case s.Overheated:
+		err = ErrOverheated
+	case !s.Online || s.Unrecoverable:
+		err = ErrOffline
+	default:
+		return nil
+	}
+	return &StatusError{Status: s, err: err}
+}
+
+// A StatusError is returned by CheckStatus if the printer reports a condition that prevents printing, and by
+// PrintBitmap, PrintBarcode, and Feed if such a condition causes a write to fail. Use errors.Is to test for ErrOffline,
+// ErrPaperOut, or ErrOverheated.
+type StatusError struct {
+	Status Status
+
+	err error
+}
+
+func (e *StatusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.err
+}
+
+// Real-time status queries (DLE EOT n) and the bits of their responses that we care about.
+const (
+	queryPrinterStatus = 1
+	queryErrorStatus   = 3
+	queryPaperStatus   = 4
+
+	printerStatusOffline = 1 << 3
+
+	errorStatusUnrecoverable = 1 << 5
+	errorStatusRecoverable   = 1 << 6
+
+	paperStatusNearEnd = 3 << 2
+	paperStatusEnd     = 3 << 5
+)
+
+func (d *Device) query(n byte) (byte, error) {
+	if err := d.write([]byte{0x10, 0x04, n}, 0); err != nil {
+		return 0, err
+	}
+
+	var response [1]byte
+	if _, err := io.ReadFull(d.r, response[:]); err != nil {
+		if err == io.EOF {
+			return 0, ErrNoResponse
+		}
+		return 0, err
+	}
+
+	// Bits 1 and 4 of every status response are set and bits 0 and 7 are clear.
+	if response[0]&0x93 != 0x12 {
+		return 0, fmt.Errorf("malformed status response 0x%02x", response[0])
+	}
+	return response[0], nil
+}
+
+// Status queries the printer's current status. The device must have been created using NewReadWriter.
+func (d *Device) Status() (Status, error) {
+	if d.r == nil {
+		return Status{}, ErrStatusUnsupported
+	}
+
+	printerStatus, err := d.query(queryPrinterStatus)
+	if err != nil {
+		return Status{}, err
+	}
+	errorStatus, err := d.query(queryErrorStatus)
+	if err != nil {
+		return Status{}, err
+	}
+	paperStatus, err := d.query(queryPaperStatus)
+	if err != nil {
+		return Status{}, err
+	}
+
+	return Status{
+		Online:        printerStatus&printerStatusOffline == 0,
+		PaperOut:      paperStatus&paperStatusEnd != 0,
+		PaperLow:      paperStatus&paperStatusNearEnd != 0,
+		Overheated:    errorStatus&errorStatusRecoverable != 0,
+		Unrecoverable: errorStatus&errorStatusUnrecoverable != 0,
+	}, nil
+}
+
+// CheckStatus returns an error if the printer reports a condition that prevents printing. Querying the status takes
+// several round trips to the printer, so callers should check it once at the start of each job rather than before
+// each line. Devices created without a readable link are assumed to be ready.
+func (d *Device) CheckStatus() error {
+	if d.r == nil {
+		return nil
+	}
+
+	status, err := d.Status()
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
+
+// writeError returns the error to report for a failed write to the printer. If the printer reports a condition that
+// explains the failure, such as running out of paper, that condition is returned; otherwise, err is returned as-is.
+func (d *Device) writeError(err error) error {
+	if d.r == nil {
+		return err
+	}
+	if status, statusErr := d.Status(); statusErr == nil && status.Err() != nil {
+		return status.Err()
+	}
+	return err
+}
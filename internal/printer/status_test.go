@@ -0,0 +1,138 @@
+package printer
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"testing"
+
+	"github.com/pgavlin/lilprinty/internal/bitmap"
+)
+
+// fakeSerial simulates a serial link to a printer. It answers real-time status queries with the configured status
+// bytes and records everything else that is written. Writes fail once failAfter bytes have been written, if set.
+type fakeSerial struct {
+	printerStatus, errorStatus, paperStatus byte
+	silent                                  bool
+
+	written   bytes.Buffer
+	queries   int
+	responses []byte
+	failAfter int
+}
+
+var errLinkDown = errors.New("link down")
+
+func newFakeSerial() *fakeSerial {
+	// Bits 1 and 4 of every status response are set.
+	return &fakeSerial{printerStatus: 0x12, errorStatus: 0x12, paperStatus: 0x12}
+}
+
+func (f *fakeSerial) Write(b []byte) (int, error) {
+	if len(b) == 3 && b[0] == 0x10 && b[1] == 0x04 {
+		f.queries++
+		if f.silent {
+			return len(b), nil
+		}
+		switch b[2] {
+		case queryPrinterStatus:
+			f.responses = append(f.responses, f.printerStatus)
+		case queryErrorStatus:
+			f.responses = append(f.responses, f.errorStatus)
+		case queryPaperStatus:
+			f.responses = append(f.responses, f.paperStatus)
+		}
+		return len(b), nil
+	}
+
+	if f.failAfter > 0 && f.written.Len()+len(b) > f.failAfter {
+		return 0, errLinkDown
+	}
+	return f.written.Write(b)
+}
+
+func (f *fakeSerial) Read(b []byte) (int, error) {
+	if len(f.responses) == 0 {
+		// A real link times out instead of blocking forever.
+		return 0, io.EOF
+	}
+	n := copy(b, f.responses)
+	f.responses = f.responses[n:]
+	return n, nil
+}
+
+func newFakeDevice(serial *fakeSerial) *Device {
+	config := DefaultConfig
+	config.BaudRate, config.DotPrintTime, config.DotFeedTime = 0, 0, 0
+	return NewReadWriter(serial, config)
+}
+
+func TestCheckStatus(t *testing.T) {
+	cases := []struct {
+		name   string
+		setup  func(f *fakeSerial)
+		target error
+	}{
+		{name: "ready", setup: func(f *fakeSerial) {}},
+		{name: "paper out", setup: func(f *fakeSerial) { f.paperStatus |= paperStatusEnd }, target: ErrPaperOut},
+		{name: "offline", setup: func(f *fakeSerial) { f.printerStatus |= printerStatusOffline }, target: ErrOffline},
+		{name: "overheated", setup: func(f *fakeSerial) { f.errorStatus |= errorStatusRecoverable }, target: ErrOverheated},
+		{name: "no response", setup: func(f *fakeSerial) { f.silent = true }, target: ErrNoResponse},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			serial := newFakeSerial()
+			c.setup(serial)
+
+			err := newFakeDevice(serial).CheckStatus()
+			switch {
+			case c.target == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case c.target != nil && !errors.Is(err, c.target):
+				t.Fatalf("expected %v, got %v", c.target, err)
+			}
+		})
+	}
+}
+
+func TestStatusIsNotQueriedPerLine(t *testing.T) {
+	serial := newFakeSerial()
+	device := newFakeDevice(serial)
+
+	img := bitmap.New(image.Rect(0, 0, 8, 8))
+	for i := 0; i < 10; i++ {
+		if err := device.PrintBitmap(img); err != nil {
+			t.Fatal(err)
+		}
+		if err := device.Feed(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if serial.queries != 0 {
+		t.Fatalf("expected no status queries, got %v", serial.queries)
+	}
+}
+
+func TestWriteErrorReportsStatus(t *testing.T) {
+	serial := newFakeSerial()
+	serial.failAfter = 1
+	device := newFakeDevice(serial)
+
+	// A failed write with a healthy printer reports the write error.
+	img := bitmap.New(image.Rect(0, 0, 8, 8))
+	if err := device.PrintBitmap(img); !errors.Is(err, errLinkDown) {
+		t.Fatalf("expected %v, got %v", errLinkDown, err)
+	}
+
+	// A failed write while the printer is out of paper or offline reports the printer's condition.
+	serial.paperStatus |= paperStatusEnd
+	if err := device.Feed(1); !errors.Is(err, ErrPaperOut) {
+		t.Fatalf("expected %v, got %v", ErrPaperOut, err)
+	}
+	serial.paperStatus &^= paperStatusEnd
+	serial.printerStatus |= printerStatusOffline
+	if err := device.PrintBitmap(img); !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected %v, got %v", ErrOffline, err)
+	}
+}
@@ -0,0 +1,132 @@
+package shortener
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Local is a self-hosted Shortener. It assigns each URL a short ID and serves redirects from its base URL plus the ID
+// to the original URL. If a store path is given, the assigned IDs are persisted to that file so that they survive
+// restarts and can be shared between processes that use the same file.
+type Local struct {
+	baseURL string
+	path    string
+
+	m    sync.Mutex
+	ids  map[string]string // URL -> ID
+	urls map[string]string // ID -> URL
+}
+
+// NewLocal creates a new local shortener that serves redirects under the given base URL, e.g.
+// "http://printer.local:8080/l/". If storePath is not empty, existing links are loaded from the file at that path
+// and new links are appended to it.
+func NewLocal(baseURL, storePath string) (*Local, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("local shortener requires a base URL")
+	}
+
+	l := &Local{
+		baseURL: baseURL,
+		path:    storePath,
+		ids:     map[string]string{},
+		urls:    map[string]string{},
+	}
+	if storePath != "" {
+		if err := l.load(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// BaseURL returns the base URL of the shortened links.
+func (l *Local) BaseURL() string {
+	return l.baseURL
+}
+
+// load reads the links in the store. Each line of the store holds an ID and a URL separated by a space.
+func (l *Local) load() error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		l.ids[fields[1]], l.urls[fields[0]] = fields[0], fields[1]
+	}
+	return scanner.Err()
+}
+
+// Shorten returns the short link for the URL, assigning it a new ID if necessary.
+func (l *Local) Shorten(url string) (string, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if id, ok := l.ids[url]; ok {
+		return l.baseURL + id, nil
+	}
+
+	// Another process may have added links to the store since it was last read, so reload it before assigning a new
+	// ID.
+	if l.path != "" {
+		if err := l.load(); err != nil {
+			return "", err
+		}
+		if id, ok := l.ids[url]; ok {
+			return l.baseURL + id, nil
+		}
+	}
+
+	id := strconv.FormatInt(int64(len(l.urls)+1), 36)
+	if l.path != "" {
+		f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return "", err
+		}
+		_, err = fmt.Fprintf(f, "%s %s\n", id, url)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	l.ids[url], l.urls[id] = id, url
+	return l.baseURL + id, nil
+}
+
+// ServeHTTP redirects requests for short links to their original URLs. The ID of the link is the last element of the
+// request path.
+func (l *Local) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	id := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+
+	l.m.Lock()
+	url, ok := l.urls[id]
+	if !ok && l.path != "" {
+		if err := l.load(); err == nil {
+			url, ok = l.urls[id]
+		}
+	}
+	l.m.Unlock()
+
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	http.Redirect(w, req, url, http.StatusFound)
+}
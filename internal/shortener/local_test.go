@@ -0,0 +1,127 @@
+package shortener
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const baseURL = "http://printer.local/l/"
+
+// shorten shortens the given URL and fails the test if the result is not the expected short link.
+func shorten(t *testing.T, l *Local, url, expected string) {
+	t.Helper()
+
+	actual, err := l.Shorten(url)
+	if err != nil {
+		t.Fatalf("shortening %v: %v", url, err)
+	}
+	if actual != expected {
+		t.Fatalf("expected %v to shorten to %v, got %v", url, expected, actual)
+	}
+}
+
+// tempStore returns the path of a store in a new temporary directory and a function that removes the directory.
+func tempStore(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "shortener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "links.txt"), func() { os.RemoveAll(dir) }
+}
+
+func TestLocalAssignsIDs(t *testing.T) {
+	l, err := NewLocal(baseURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// IDs are assigned in order in base 36, and a URL keeps its ID.
+	shorten(t, l, "https://example.com/a", baseURL+"1")
+	shorten(t, l, "https://example.com/b", baseURL+"2")
+	shorten(t, l, "https://example.com/a", baseURL+"1")
+	for i := 3; i <= 36; i++ {
+		shorten(t, l, "https://example.com/"+strconv.Itoa(i), baseURL+strconv.FormatInt(int64(i), 36))
+	}
+	shorten(t, l, "https://example.com/last", baseURL+"11")
+
+	if _, err := NewLocal("", ""); err == nil {
+		t.Fatal("expected an error for a missing base URL")
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+
+	first, err := NewLocal(baseURL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shorten(t, first, "https://example.com/a", baseURL+"1")
+	shorten(t, first, "https://example.com/b", baseURL+"2")
+
+	// A new shortener that uses the same store keeps the assigned IDs.
+	second, err := NewLocal(baseURL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shorten(t, second, "https://example.com/b", baseURL+"2")
+
+	// Shorteners that share a store reload it before assigning IDs, so they never assign the same ID twice.
+	shorten(t, second, "https://example.com/c", baseURL+"3")
+	shorten(t, first, "https://example.com/d", baseURL+"4")
+	shorten(t, first, "https://example.com/c", baseURL+"3")
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "1 https://example.com/a\n2 https://example.com/b\n3 https://example.com/c\n4 https://example.com/d\n"
+	if string(contents) != expected {
+		t.Fatalf("expected store %q, got %q", expected, contents)
+	}
+}
+
+func TestLocalServeHTTP(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+
+	l, err := NewLocal(baseURL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shorten(t, l, "https://example.com/a?b=c", baseURL+"1")
+
+	// Links added to the store by another shortener are served as well.
+	other, err := NewLocal(baseURL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shorten(t, other, "https://example.com/other", baseURL+"2")
+
+	cases := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{path: "/l/1", status: http.StatusFound, location: "https://example.com/a?b=c"},
+		{path: "/l/2", status: http.StatusFound, location: "https://example.com/other"},
+		{path: "/l/3", status: http.StatusNotFound},
+		{path: "/l/", status: http.StatusNotFound},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.status {
+			t.Errorf("%v: expected status %v, got %v", c.path, c.status, w.Code)
+		}
+		if location := w.Header().Get("Location"); location != c.location {
+			t.Errorf("%v: expected location %q, got %q", c.path, c.location, location)
+		}
+	}
+}
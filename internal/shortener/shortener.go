@@ -0,0 +1,34 @@
+package shortener
+
+import (
+	"net/url"
+
+	"github.com/pgavlin/lilprinty/internal/util"
+)
+
+// A Shortener shortens URLs so that they can be encoded into smaller link codes.
+type Shortener interface {
+	Shorten(url string) (string, error)
+}
+
+// None is a Shortener that returns URLs unchanged so that they are encoded in full.
+type None struct{}
+
+// Shorten returns the URL unchanged.
+func (None) Shorten(url string) (string, error) {
+	return url, nil
+}
+
+// TinyURL is a Shortener that shortens URLs using the tinyurl.com API. Every URL it shortens is sent to a third party.
+type TinyURL struct{}
+
+// Shorten shortens the URL using the tinyurl.com API.
+func (TinyURL) Shorten(urlString string) (string, error) {
+	const baseURL = "http://tinyurl.com/api-create.php?url="
+
+	contents, _, err := util.DownloadFile(baseURL + url.QueryEscape(urlString))
+	if err != nil {
+		return "", err
+	}
+	return string(contents), nil
+}
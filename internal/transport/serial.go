@@ -0,0 +1,176 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// linuxBaudRates is the set of baud rates supported by serial ports on Linux.
+var linuxBaudRates = map[int]bool{
+	50: true, 75: true, 110: true, 134: true, 150: true, 200: true, 300: true, 600: true, 1200: true, 1800: true,
+	2400: true, 4800: true, 9600: true, 19200: true, 38400: true, 57600: true, 115200: true, 230400: true,
+	460800: true, 500000: true, 576000: true, 921600: true, 1000000: true, 1152000: true, 1500000: true,
+	2000000: true, 2500000: true, 3000000: true, 3500000: true, 4000000: true,
+}
+
+// maxSerialReadTimeout is the longest read timeout supported by serial ports on POSIX systems, which measure the
+// timeout in tenths of a second using a single byte.
+const maxSerialReadTimeout = 255 * 100 * time.Millisecond
+
+// parseSerialConfig parses the serial port configuration described by a serial URI, e.g.
+// serial:///dev/ttyUSB0?baud=19200&databits=8&parity=none&stopbits=1&timeout=500ms. Parameters that are not
+// present take the following defaults: 9600 baud, 8 data bits, no parity, 1 stop bit, and a 500ms read timeout.
+//
+// The returned error explains why a configuration is unsupported on the current platform.
+func parseSerialConfig(u *url.URL) (*serial.Config, error) {
+	name := u.Opaque
+	if name == "" {
+		name = u.Host + u.Path
+	}
+	if name == "" {
+		return nil, fmt.Errorf("serial URIs must name a port")
+	}
+
+	query := u.Query()
+	for key := range query {
+		switch key {
+		case "baud", "databits", "parity", "stopbits", "timeout":
+		default:
+			return nil, fmt.Errorf("unknown serial parameter '%v'; supported parameters are baud, databits, parity, "+
+				"stopbits, and timeout", key)
+		}
+	}
+
+	config := &serial.Config{Name: name, Baud: 9600, Size: 8, Parity: serial.ParityNone, StopBits: serial.Stop1}
+
+	if value := query.Get("baud"); value != "" {
+		baud, err := strconv.Atoi(value)
+		if err != nil || baud <= 0 {
+			return nil, fmt.Errorf("baud must be a positive integer")
+		}
+		config.Baud = baud
+	}
+
+	if value := query.Get("databits"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 5 || size > 8 {
+			return nil, fmt.Errorf("databits must be 5, 6, 7, or 8")
+		}
+		config.Size = byte(size)
+	}
+
+	switch strings.ToLower(query.Get("parity")) {
+	case "", "n", "none":
+		config.Parity = serial.ParityNone
+	case "o", "odd":
+		config.Parity = serial.ParityOdd
+	case "e", "even":
+		config.Parity = serial.ParityEven
+	case "m", "mark":
+		config.Parity = serial.ParityMark
+	case "s", "space":
+		config.Parity = serial.ParitySpace
+	default:
+		return nil, fmt.Errorf("parity must be none, odd, even, mark, or space")
+	}
+
+	switch query.Get("stopbits") {
+	case "", "1":
+		config.StopBits = serial.Stop1
+	case "1.5":
+		config.StopBits = serial.Stop1Half
+	case "2":
+		config.StopBits = serial.Stop2
+	default:
+		return nil, fmt.Errorf("stopbits must be 1, 1.5, or 2")
+	}
+
+	timeout, err := readTimeout(u)
+	if err != nil {
+		return nil, err
+	}
+	config.ReadTimeout = timeout
+
+	if err := validateSerialConfig(config, runtime.GOOS); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// validateSerialConfig checks that the given configuration is supported by the serial port implementation for the
+// given operating system.
+func validateSerialConfig(config *serial.Config, goos string) error {
+	if goos == "windows" {
+		if config.StopBits == serial.Stop1Half && config.Size != 5 {
+			return fmt.Errorf("1.5 stop bits may only be used with 5 data bits")
+		}
+		if config.StopBits == serial.Stop2 && config.Size == 5 {
+			return fmt.Errorf("2 stop bits may not be used with 5 data bits; use 1.5 stop bits instead")
+		}
+		return nil
+	}
+
+	if goos == "linux" && !linuxBaudRates[config.Baud] {
+		rates := make([]int, 0, len(linuxBaudRates))
+		for rate := range linuxBaudRates {
+			rates = append(rates, rate)
+		}
+		sort.Ints(rates)
+
+		names := make([]string, len(rates))
+		for i, rate := range rates {
+			names[i] = strconv.Itoa(rate)
+		}
+		return fmt.Errorf("%d baud is not supported on Linux; supported rates are %v", config.Baud,
+			strings.Join(names, ", "))
+	}
+
+	switch {
+	case config.StopBits == serial.Stop1Half:
+		return fmt.Errorf("1.5 stop bits are only supported on Windows; use 1 or 2 stop bits")
+	case config.Parity == serial.ParityMark || config.Parity == serial.ParitySpace:
+		return fmt.Errorf("mark and space parity are only supported on Windows; use none, odd, or even parity")
+	case config.ReadTimeout > maxSerialReadTimeout:
+		return fmt.Errorf("timeout must be at most %v", maxSerialReadTimeout)
+	case config.ReadTimeout < 100*time.Millisecond:
+		return fmt.Errorf("timeout must be at least 100ms, as serial read timeouts are measured in tenths of a second")
+	}
+	return nil
+}
+
+// bitsPerByte returns the number of bits sent over a serial link with the given configuration for each byte of data:
+// a start bit, the data bits, the parity bit if any, and the stop bits.
+func bitsPerByte(config *serial.Config) float64 {
+	bits := 1 + float64(config.Size)
+	if config.Parity != serial.ParityNone {
+		bits++
+	}
+	switch config.StopBits {
+	case serial.Stop1Half:
+		bits += 1.5
+	case serial.Stop2:
+		bits += 2
+	default:
+		bits++
+	}
+	return bits
+}
+
+func serialDialer(u *url.URL) (dialer, int, float64, error) {
+	config, err := parseSerialConfig(u)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return func(reconnect bool) (io.ReadWriteCloser, error) {
+		return serial.OpenPort(config)
+	}, config.Baud, bitsPerByte(config), nil
+}
@@ -0,0 +1,153 @@
+package transport
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+func TestParseSerialConfig(t *testing.T) {
+	defaults := serial.Config{Name: "/dev/ttyUSB0", Baud: 9600, Size: 8, Parity: serial.ParityNone,
+		StopBits: serial.Stop1, ReadTimeout: defaultReadTimeout}
+
+	cases := []struct {
+		uri      string
+		expected func(c *serial.Config)
+		err      string
+	}{
+		{uri: "serial:///dev/ttyUSB0", expected: func(c *serial.Config) {}},
+		{uri: "serial:COM3", expected: func(c *serial.Config) { c.Name = "COM3" }},
+		{uri: "serial:///dev/ttyUSB0?baud=19200", expected: func(c *serial.Config) { c.Baud = 19200 }},
+		{uri: "serial:///dev/ttyUSB0?databits=7&parity=even", expected: func(c *serial.Config) {
+			c.Size, c.Parity = 7, serial.ParityEven
+		}},
+		{uri: "serial:///dev/ttyUSB0?parity=O&stopbits=2", expected: func(c *serial.Config) {
+			c.Parity, c.StopBits = serial.ParityOdd, serial.Stop2
+		}},
+		{uri: "serial:///dev/ttyUSB0?timeout=2s", expected: func(c *serial.Config) { c.ReadTimeout = 2 * time.Second }},
+
+		{uri: "serial://", err: "serial URIs must name a port"},
+		{uri: "serial:///dev/ttyUSB0?speed=9600", err: "unknown serial parameter 'speed'; supported parameters are baud"},
+		{uri: "serial:///dev/ttyUSB0?baud=fast", err: "baud must be a positive integer"},
+		{uri: "serial:///dev/ttyUSB0?baud=-9600", err: "baud must be a positive integer"},
+		{uri: "serial:///dev/ttyUSB0?databits=9", err: "databits must be 5, 6, 7, or 8"},
+		{uri: "serial:///dev/ttyUSB0?parity=high", err: "parity must be none, odd, even, mark, or space"},
+		{uri: "serial:///dev/ttyUSB0?stopbits=3", err: "stopbits must be 1, 1.5, or 2"},
+		{uri: "serial:///dev/ttyUSB0?timeout=soon", err: "timeout must be a positive duration"},
+		{uri: "serial:///dev/ttyUSB0?timeout=-1s", err: "timeout must be a positive duration"},
+	}
+	for _, c := range cases {
+		t.Run(c.uri, func(t *testing.T) {
+			u, err := url.Parse(c.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := parseSerialConfig(u)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected error %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := defaults
+			c.expected(&expected)
+			if *actual != expected {
+				t.Fatalf("expected %+v, got %+v", expected, *actual)
+			}
+		})
+	}
+}
+
+func TestValidateSerialConfig(t *testing.T) {
+	cases := []struct {
+		name, goos string
+		setup      func(c *serial.Config)
+		err        string
+	}{
+		{name: "defaults", goos: "linux"},
+
+		// Linux only supports a fixed set of baud rates, while other POSIX systems pass any rate to the driver.
+		{name: "standard baud rate", goos: "linux", setup: func(c *serial.Config) { c.Baud = 115200 }},
+		{name: "unsupported baud rate", goos: "linux", setup: func(c *serial.Config) { c.Baud = 12345 },
+			err: "12345 baud is not supported on Linux; supported rates are 50, 75, 110"},
+		{name: "custom baud rate", goos: "darwin", setup: func(c *serial.Config) { c.Baud = 12345 }},
+		{name: "custom baud rate on Windows", goos: "windows", setup: func(c *serial.Config) { c.Baud = 12345 }},
+
+		// Only Windows supports 1.5 stop bits, and then only with 5 data bits, for which it does not support 2 stop bits.
+		{name: "1.5 stop bits", goos: "linux", setup: func(c *serial.Config) { c.StopBits = serial.Stop1Half },
+			err: "1.5 stop bits are only supported on Windows; use 1 or 2 stop bits"},
+		{name: "1.5 stop bits on macOS", goos: "darwin", setup: func(c *serial.Config) { c.StopBits = serial.Stop1Half },
+			err: "1.5 stop bits are only supported on Windows"},
+		{name: "1.5 stop bits on Windows", goos: "windows", setup: func(c *serial.Config) {
+			c.Size, c.StopBits = 5, serial.Stop1Half
+		}},
+		{name: "1.5 stop bits with 8 data bits on Windows", goos: "windows", setup: func(c *serial.Config) {
+			c.StopBits = serial.Stop1Half
+		}, err: "1.5 stop bits may only be used with 5 data bits"},
+		{name: "2 stop bits with 5 data bits on Windows", goos: "windows", setup: func(c *serial.Config) {
+			c.Size, c.StopBits = 5, serial.Stop2
+		}, err: "2 stop bits may not be used with 5 data bits; use 1.5 stop bits instead"},
+		{name: "2 stop bits with 5 data bits", goos: "linux", setup: func(c *serial.Config) {
+			c.Size, c.StopBits = 5, serial.Stop2
+		}},
+
+		// Only Windows supports mark and space parity.
+		{name: "mark parity", goos: "linux", setup: func(c *serial.Config) { c.Parity = serial.ParityMark },
+			err: "mark and space parity are only supported on Windows; use none, odd, or even parity"},
+		{name: "space parity", goos: "freebsd", setup: func(c *serial.Config) { c.Parity = serial.ParitySpace },
+			err: "mark and space parity are only supported on Windows"},
+		{name: "mark parity on Windows", goos: "windows", setup: func(c *serial.Config) { c.Parity = serial.ParityMark }},
+		{name: "space parity on Windows", goos: "windows", setup: func(c *serial.Config) { c.Parity = serial.ParitySpace }},
+
+		// POSIX systems measure read timeouts in tenths of a second using a single byte.
+		{name: "longest timeout", goos: "linux", setup: func(c *serial.Config) { c.ReadTimeout = maxSerialReadTimeout }},
+		{name: "long timeout", goos: "linux", setup: func(c *serial.Config) { c.ReadTimeout = 26 * time.Second },
+			err: "timeout must be at most 25.5s"},
+		{name: "short timeout", goos: "darwin", setup: func(c *serial.Config) { c.ReadTimeout = 50 * time.Millisecond },
+			err: "timeout must be at least 100ms, as serial read timeouts are measured in tenths of a second"},
+		{name: "timeouts on Windows", goos: "windows", setup: func(c *serial.Config) { c.ReadTimeout = time.Minute }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := serial.Config{Name: "port", Baud: 9600, Size: 8, Parity: serial.ParityNone, StopBits: serial.Stop1,
+				ReadTimeout: defaultReadTimeout}
+			if c.setup != nil {
+				c.setup(&config)
+			}
+
+			err := validateSerialConfig(&config, c.goos)
+			switch {
+			case c.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+				t.Fatalf("expected error %q, got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestBitsPerByte(t *testing.T) {
+	cases := []struct {
+		config   serial.Config
+		expected float64
+	}{
+		{config: serial.Config{Size: 8, Parity: serial.ParityNone, StopBits: serial.Stop1}, expected: 10},
+		{config: serial.Config{Size: 8, Parity: serial.ParityEven, StopBits: serial.Stop1}, expected: 11},
+		{config: serial.Config{Size: 8, Parity: serial.ParityOdd, StopBits: serial.Stop2}, expected: 12},
+		{config: serial.Config{Size: 7, Parity: serial.ParityNone, StopBits: serial.Stop2}, expected: 10},
+		{config: serial.Config{Size: 5, Parity: serial.ParityMark, StopBits: serial.Stop1Half}, expected: 8.5},
+	}
+	for _, c := range cases {
+		if actual := bitsPerByte(&c.config); actual != c.expected {
+			t.Errorf("%+v: expected %v, got %v", c.config, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	// defaultReadTimeout bounds reads from the printer so that status queries fail rather than hang if the printer
+	// does not answer.
+	defaultReadTimeout = 500 * time.Millisecond
+
+	// maxAttempts is the number of times a failed write is attempted before the error is returned to the caller.
+	maxAttempts = 5
+	// initialBackoff is the time to wait before the first reconnection attempt. The wait doubles after each attempt.
+	initialBackoff = 500 * time.Millisecond
+)
+
+// A dialer opens a connection to the printer. reconnect is true if the connection is being reopened after a failure.
+type dialer func(reconnect bool) (io.ReadWriteCloser, error)
+
+// A Conn is a connection to a printer. If a write to the printer fails, the connection is reopened and the write is
+// retried in full. Each write should therefore hold whole printer commands.
+type Conn struct {
+	// OnReconnect, if set, is called with the new connection after the connection is reopened and before the failed
+	// write is retried. A printer that lost its connection may also have lost power, so this is typically used to
+	// reinitialize the printer.
+	OnReconnect func(w io.Writer) error
+
+	uri         string
+	baudRate    int
+	bitsPerByte float64
+	dial        dialer
+	conn        io.ReadWriteCloser
+
+	sleep func(time.Duration)
+}
+
+// Open opens a connection to the printer described by the given URI. The following schemes are supported:
+//
+//   - serial:///dev/ttyUSB0?baud=19200 opens a serial port. The baud, databits, parity, and stopbits parameters
+//     configure the port and default to 9600 baud, 8 data bits, no parity, and 1 stop bit.
+//   - tcp://host:9100 opens a raw TCP connection. The port defaults to 9100.
+//   - file:///path opens a file or device node for writing. Relative paths may be written file:path.
+//
+// The serial and tcp schemes accept a timeout parameter that bounds reads from the printer, e.g. timeout=1s.
+func Open(uri string) (*Conn, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Conn{uri: uri, sleep: time.Sleep}
+	switch u.Scheme {
+	case "serial":
+		c.dial, c.baudRate, c.bitsPerByte, err = serialDialer(u)
+	case "tcp":
+		c.dial, err = tcpDialer(u)
+	case "file":
+		c.dial, err = fileDialer(u)
+	default:
+		return nil, fmt.Errorf("unsupported printer scheme '%v'", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.conn, err = c.dial(false); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// BaudRate returns the baud rate of the connection if it is a serial connection, and zero otherwise.
+func (c *Conn) BaudRate() int {
+	return c.baudRate
+}
+
+// BitsPerByte returns the number of bits sent for each byte of data, including the start, parity, and stop bits, if the
+// connection is a serial connection, and zero otherwise.
+func (c *Conn) BitsPerByte() float64 {
+	return c.bitsPerByte
+}
+
+// Read reads from the printer. Reads that time out return io.EOF.
+func (c *Conn) Read(b []byte) (int, error) {
+	if c.conn == nil {
+		return 0, fmt.Errorf("connection to '%v' is closed", c.uri)
+	}
+	return c.conn.Read(b)
+}
+
+// Write writes to the printer, reopening the connection and retrying if the write fails. The printer may have received
+// part of a failed write, so a retry sends all of b again rather than just the part that was not written: the printer
+// may have reset while the connection was down, and the tail of a command would be read as garbage.
+func (c *Conn) Write(b []byte) (int, error) {
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		if c.conn != nil {
+			n, err := c.conn.Write(b)
+			if err == nil {
+				return n, nil
+			}
+			if attempt == maxAttempts {
+				return 0, err
+			}
+
+			c.conn.Close()
+			c.conn = nil
+		}
+
+		c.sleep(backoff)
+		backoff *= 2
+
+		conn, err := c.dial(true)
+		if err == nil && c.OnReconnect != nil {
+			if err = c.OnReconnect(conn); err != nil {
+				conn.Close()
+			}
+		}
+		if err != nil {
+			if attempt == maxAttempts {
+				return 0, fmt.Errorf("reconnecting to '%v': %w", c.uri, err)
+			}
+			continue
+		}
+		c.conn = conn
+	}
+}
+
+// Close closes the connection to the printer.
+func (c *Conn) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func readTimeout(u *url.URL) (time.Duration, error) {
+	value := u.Query().Get("timeout")
+	if value == "" {
+		return defaultReadTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be a positive duration")
+	}
+	return timeout, nil
+}
+
+func tcpDialer(u *url.URL) (dialer, error) {
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("tcp URIs must name a host")
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "9100")
+	}
+
+	timeout, err := readTimeout(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(reconnect bool) (io.ReadWriteCloser, error) {
+		conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		return &tcpConn{Conn: conn, timeout: timeout}, nil
+	}, nil
+}
+
+// tcpConn applies a read timeout to a TCP connection.
+type tcpConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *tcpConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(b)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		// Report timeouts the same way as serial ports do.
+		return n, io.EOF
+	}
+	return n, err
+}
+
+func fileDialer(u *url.URL) (dialer, error) {
+	// file:out.bin and file://out.bin name relative paths, as does file://./out.bin. Only an empty host or localhost
+	// introduces an absolute path.
+	path := u.Path
+	switch {
+	case u.Opaque != "":
+		path = u.Opaque
+	case u.Host != "" && u.Host != "localhost":
+		path = u.Host + u.Path
+	}
+	if path == "" {
+		return nil, fmt.Errorf("file URIs must name a file")
+	}
+
+	return func(reconnect bool) (io.ReadWriteCloser, error) {
+		// Truncate the file when it is first opened, but append to it when reconnecting so that earlier output is
+		// preserved.
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if reconnect {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		return os.OpenFile(path, flags, 0644)
+	}, nil
+}
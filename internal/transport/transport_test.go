@@ -0,0 +1,193 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// brokenConn accepts up to limit bytes and then fails, simulating a link that drops partway through a command.
+type brokenConn struct {
+	bytes.Buffer
+	limit int
+}
+
+var errBroken = errors.New("broken pipe")
+
+func (c *brokenConn) Write(b []byte) (int, error) {
+	if c.limit >= 0 && c.Len()+len(b) > c.limit {
+		n, _ := c.Buffer.Write(b[:c.limit-c.Len()])
+		return n, errBroken
+	}
+	return c.Buffer.Write(b)
+}
+
+func (c *brokenConn) Close() error {
+	return nil
+}
+
+func TestWriteRetriesWholeCommand(t *testing.T) {
+	first, second := &brokenConn{limit: 3}, &brokenConn{limit: -1}
+	c := &Conn{
+		uri:   "test:",
+		conn:  first,
+		sleep: func(time.Duration) {},
+		dial: func(reconnect bool) (io.ReadWriteCloser, error) {
+			if !reconnect {
+				t.Fatal("expected a reconnection")
+			}
+			return second, nil
+		},
+	}
+	c.OnReconnect = func(w io.Writer) error {
+		_, err := w.Write([]byte{0x1b, 0x40})
+		return err
+	}
+
+	cmd := []byte{0x12, 0x2a, 1, 2, 0xff, 0xff}
+	if n, err := c.Write(cmd); err != nil || n != len(cmd) {
+		t.Fatalf("unexpected result (%v, %v)", n, err)
+	}
+
+	// The new connection receives the reset followed by the whole command, not just its unwritten tail.
+	expected := append([]byte{0x1b, 0x40}, cmd...)
+	if !bytes.Equal(second.Bytes(), expected) {
+		t.Fatalf("expected % x, got % x", expected, second.Bytes())
+	}
+}
+
+func TestWriteGivesUp(t *testing.T) {
+	dials := 0
+	c := &Conn{
+		uri:   "test:",
+		conn:  &brokenConn{limit: 0},
+		sleep: func(time.Duration) {},
+		dial: func(reconnect bool) (io.ReadWriteCloser, error) {
+			dials++
+			return &brokenConn{limit: 0}, nil
+		},
+	}
+	if _, err := c.Write([]byte{0x1b, 0x4a, 1}); !errors.Is(err, errBroken) {
+		t.Fatalf("expected %v, got %v", errBroken, err)
+	}
+	if dials != maxAttempts-1 {
+		t.Fatalf("expected %v reconnections, got %v", maxAttempts-1, dials)
+	}
+}
+
+func TestTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer listener.Close()
+
+	// Each accepted connection is delivered along with everything read from it.
+	type received struct {
+		conn net.Conn
+		data chan []byte
+	}
+	accepted := make(chan received)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			r := received{conn: conn, data: make(chan []byte, 1)}
+			accepted <- r
+			go func() {
+				data, _ := ioutil.ReadAll(conn)
+				r.data <- data
+			}()
+		}
+	}()
+
+	c, err := Open("tcp://" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.sleep = func(time.Duration) {}
+	reconnected := false
+	c.OnReconnect = func(w io.Writer) error {
+		reconnected = true
+		_, err := w.Write([]byte{0x1b, 0x40})
+		return err
+	}
+
+	first := <-accepted
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the connection from the printer's end. Writes to a dropped TCP connection may be buffered by the kernel
+	// before the drop is noticed, so keep writing until the client reconnects.
+	first.conn.Close()
+	cmd := []byte{0x1b, 0x4a, 1}
+	for !reconnected {
+		if _, err := c.Write(cmd); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	second := <-accepted
+	c.Close()
+
+	expected := append([]byte{0x1b, 0x40}, cmd...)
+	if data := <-second.data; !bytes.Equal(data, expected) {
+		t.Fatalf("expected % x, got % x", expected, data)
+	}
+}
+
+func TestFileURIs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	absolute := filepath.Join(dir, "absolute.bin")
+	cases := map[string]string{
+		"file:relative.bin":   "relative.bin",
+		"file://host.bin":     "host.bin",
+		"file://./dotted.bin": "dotted.bin",
+		(&url.URL{Scheme: "file", Path: absolute}).String(): absolute,
+	}
+	for uri, path := range cases {
+		c, err := Open(uri)
+		if err != nil {
+			t.Fatalf("opening '%v': %v", uri, err)
+		}
+		_, err = c.Write([]byte(uri))
+		c.Close()
+		if err != nil {
+			t.Fatalf("writing '%v': %v", uri, err)
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading '%v': %v", path, err)
+		}
+		if string(data) != uri {
+			t.Fatalf("expected '%v' in '%v', got '%v'", uri, path, data)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 )
 
 // DownloadFile downloads the file at the given URL using the HTTP GET method and returns its contents and MIME type.
@@ -27,3 +32,62 @@ func DownloadFile(url string) ([]byte, string, error) {
 	}
 	return contents, contentType, nil
 }
+
+// DownloadCachedFile downloads the file at the given URL using DownloadFile and keeps a copy of its contents in the
+// given cache directory. Later calls for the same URL return the cached copy without making a request. Failures to
+// write the cache are ignored. If dir is empty, the file is downloaded without caching.
+func DownloadCachedFile(url, dir string) ([]byte, error) {
+	if dir == "" {
+		contents, _, err := DownloadFile(url)
+		return contents, err
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	path := filepath.Join(dir, hex.EncodeToString(sum[:]))
+	if contents, err := ioutil.ReadFile(path); err == nil {
+		return contents, nil
+	}
+
+	contents, _, err := DownloadFile(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the cached copy to a temporary file first so that concurrent readers never observe a partial file.
+	if err := os.MkdirAll(dir, 0755); err == nil {
+		if f, err := ioutil.TempFile(dir, ".download-*"); err == nil {
+			_, err = f.Write(contents)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err == nil {
+				err = os.Rename(f.Name(), path)
+			}
+			if err != nil {
+				os.Remove(f.Name())
+			}
+		}
+	}
+	return contents, nil
+}
+
+// LoadFile loads the file at the given location and returns its contents and MIME type. The location may be a
+// file:// URL or a local path, in which case the file is read from disk, or any other URL, in which case the file is
+// downloaded using DownloadFile.
+func LoadFile(location string) ([]byte, string, error) {
+	// Windows drive letters parse as single-letter schemes, so treat those as paths as well.
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme == "" || u.Scheme == "file" || len(u.Scheme) == 1 {
+		path := location
+		if err == nil && u.Scheme == "file" {
+			path = u.Path
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return contents, http.DetectContentType(contents), nil
+	}
+	return DownloadFile(location)
+}
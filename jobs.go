@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxFinishedJobs is the number of finished jobs that are retained for inspection.
+const maxFinishedJobs = 100
+
+type jobState string
+
+const (
+	jobQueued   jobState = "queued"
+	jobPrinting jobState = "printing"
+	jobPrinted  jobState = "printed"
+	jobFailed   jobState = "failed"
+	jobCanceled jobState = "canceled"
+)
+
+func (s jobState) finished() bool {
+	return s == jobPrinted || s == jobFailed || s == jobCanceled
+}
+
+var (
+	errJobNotFound = errors.New("job not found")
+	errJobFinished = errors.New("job has already finished")
+	errJobCanceled = errors.New("job was canceled")
+)
+
+// A job is a document submitted for printing.
+type job struct {
+	ID        string    `json:"id"`
+	State     jobState  `json:"state"`
+	Submitted time.Time `json:"submitted"`
+	Height    int       `json:"height,omitempty"` // The height of the rendered document in dots.
+	Error     string    `json:"error,omitempty"`  // The reason the job failed, if it did.
+
+	contents []byte
+	canceled bool
+}
+
+// A jobQueue prints submitted jobs one at a time in submission order.
+type jobQueue struct {
+	m       sync.Mutex
+	ready   *sync.Cond
+	jobs    map[string]*job
+	order   []*job
+	pending []*job
+	nextID  int
+
+	print func(j *job, canceled func() bool) error
+}
+
+// newJobQueue creates a new job queue and starts its worker. print is called for each job that has not been canceled
+// before its turn comes. It should poll canceled and return errJobCanceled once canceled returns true.
+func newJobQueue(print func(j *job, canceled func() bool) error) *jobQueue {
+	q := &jobQueue{
+		jobs:  map[string]*job{},
+		print: print,
+	}
+	q.ready = sync.NewCond(&q.m)
+	go q.run()
+	return q
+}
+
+// submit adds a job for the given contents to the queue and returns a snapshot of the job.
+func (q *jobQueue) submit(contents []byte) job {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	q.nextID++
+	j := &job{
+		ID:        strconv.Itoa(q.nextID),
+		State:     jobQueued,
+		Submitted: time.Now().UTC(),
+		contents:  contents,
+	}
+	q.jobs[j.ID] = j
+	q.order = append(q.order, j)
+	q.pending = append(q.pending, j)
+	q.ready.Signal()
+	return *j
+}
+
+// get returns a snapshot of the job with the given ID.
+func (q *jobQueue) get(id string) (job, error) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return job{}, errJobNotFound
+	}
+	return *j, nil
+}
+
+// list returns snapshots of the jobs in the queue in submission order.
+func (q *jobQueue) list() []job {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	jobs := make([]job, len(q.order))
+	for i, j := range q.order {
+		jobs[i] = *j
+	}
+	return jobs
+}
+
+// cancel cancels the job with the given ID. A job that is printing stops at the next opportunity.
+func (q *jobQueue) cancel(id string) (job, error) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	j, ok := q.jobs[id]
+	switch {
+	case !ok:
+		return job{}, errJobNotFound
+	case j.State.finished():
+		return *j, errJobFinished
+	}
+
+	j.canceled = true
+	if j.State == jobQueued {
+		j.State, j.contents = jobCanceled, nil
+		q.prune()
+	}
+	return *j, nil
+}
+
+func (q *jobQueue) run() {
+	for {
+		j := q.next()
+		err := q.print(j, func() bool {
+			q.m.Lock()
+			defer q.m.Unlock()
+			return j.canceled
+		})
+		q.finish(j, err)
+	}
+}
+
+// next waits for the next job that has not been canceled and marks it as printing.
+func (q *jobQueue) next() *job {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	for {
+		for len(q.pending) == 0 {
+			q.ready.Wait()
+		}
+
+		j := q.pending[0]
+		q.pending[0], q.pending = nil, q.pending[1:]
+		if !j.canceled {
+			j.State = jobPrinting
+			return j
+		}
+	}
+}
+
+// setHeight records the rendered height of a job.
+func (q *jobQueue) setHeight(j *job, height int) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	j.Height = height
+}
+
+func (q *jobQueue) finish(j *job, err error) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	switch {
+	case err == nil:
+		j.State = jobPrinted
+	case errors.Is(err, errJobCanceled):
+		j.State = jobCanceled
+	default:
+		j.State, j.Error = jobFailed, err.Error()
+	}
+	j.contents = nil
+	q.prune()
+}
+
+// prune discards the oldest finished jobs once more than maxFinishedJobs have accumulated. The queue's lock must be
+// held.
+func (q *jobQueue) prune() {
+	finished := 0
+	for _, j := range q.order {
+		if j.State.finished() {
+			finished++
+		}
+	}
+
+	order := q.order[:0]
+	for _, j := range q.order {
+		if finished > maxFinishedJobs && j.State.finished() {
+			delete(q.jobs, j.ID)
+			finished--
+			continue
+		}
+		order = append(order, j)
+	}
+	q.order = order
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// waitForState waits for the job with the given ID to reach the given state.
+func waitForState(t *testing.T, q *jobQueue, id string, state jobState) job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j, err := q.get(id)
+		if err != nil {
+			t.Fatalf("getting job %v: %v", id, err)
+		}
+		if j.State == state {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %v is %v, not %v", id, j.State, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// blockingPrinter is a fake print function that reports each job it starts and then waits to be released. If the job
+// is canceled while it waits, it returns errJobCanceled.
+type blockingPrinter struct {
+	started chan string
+	release chan error
+}
+
+func newBlockingPrinter() *blockingPrinter {
+	return &blockingPrinter{started: make(chan string, 1), release: make(chan error)}
+}
+
+func (p *blockingPrinter) print(j *job, canceled func() bool) error {
+	p.started <- string(j.contents)
+	for {
+		select {
+		case err := <-p.release:
+			return err
+		case <-time.After(time.Millisecond):
+			if canceled() {
+				return errJobCanceled
+			}
+		}
+	}
+}
+
+func TestJobQueueOrder(t *testing.T) {
+	printer := newBlockingPrinter()
+	q := newJobQueue(printer.print)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, q.submit([]byte(strconv.Itoa(i))).ID)
+	}
+
+	// Jobs print one at a time in submission order, and later jobs wait their turn.
+	for i, id := range ids {
+		if contents := <-printer.started; contents != strconv.Itoa(i) {
+			t.Fatalf("expected job %v to print, got %v", i, contents)
+		}
+		waitForState(t, q, id, jobPrinting)
+		for _, later := range ids[i+1:] {
+			if j, _ := q.get(later); j.State != jobQueued {
+				t.Fatalf("expected job %v to be queued, got %v", later, j.State)
+			}
+		}
+		printer.release <- nil
+		waitForState(t, q, id, jobPrinted)
+	}
+
+	jobs := q.list()
+	if len(jobs) != len(ids) {
+		t.Fatalf("expected %v jobs, got %v", len(ids), len(jobs))
+	}
+	for i, j := range jobs {
+		if j.ID != ids[i] {
+			t.Fatalf("expected job %v at index %v, got %v", ids[i], i, j.ID)
+		}
+	}
+}
+
+func TestJobQueueCancel(t *testing.T) {
+	printer := newBlockingPrinter()
+	q := newJobQueue(printer.print)
+
+	running, queued, last := q.submit([]byte("running")), q.submit([]byte("queued")), q.submit([]byte("last"))
+	<-printer.started
+
+	// A queued job is canceled immediately and never prints.
+	j, err := q.cancel(queued.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.State != jobCanceled {
+		t.Fatalf("expected the queued job to be canceled, got %v", j.State)
+	}
+
+	// A running job keeps printing until the print function notices that it was canceled.
+	if j, err = q.cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	if j.State != jobPrinting {
+		t.Fatalf("expected the running job to be printing, got %v", j.State)
+	}
+	waitForState(t, q, running.ID, jobCanceled)
+
+	if contents := <-printer.started; contents != "last" {
+		t.Fatalf("expected the last job to print, got %v", contents)
+	}
+	printer.release <- nil
+	waitForState(t, q, last.ID, jobPrinted)
+
+	// Finished and unknown jobs cannot be canceled.
+	for _, id := range []string{queued.ID, running.ID, last.ID} {
+		if _, err := q.cancel(id); err != errJobFinished {
+			t.Fatalf("expected %v canceling job %v, got %v", errJobFinished, id, err)
+		}
+	}
+	if _, err := q.cancel("missing"); err != errJobNotFound {
+		t.Fatalf("expected %v, got %v", errJobNotFound, err)
+	}
+}
+
+func TestJobQueueFailure(t *testing.T) {
+	printer := newBlockingPrinter()
+	q := newJobQueue(printer.print)
+
+	id := q.submit([]byte("failing")).ID
+	<-printer.started
+	printer.release <- errJobFinished
+
+	if j := waitForState(t, q, id, jobFailed); j.Error != errJobFinished.Error() {
+		t.Fatalf("expected error %q, got %q", errJobFinished.Error(), j.Error)
+	}
+}
+
+func TestJobQueuePrune(t *testing.T) {
+	printer := newBlockingPrinter()
+	q := newJobQueue(printer.print)
+
+	// Hold the first job in the printer while the rest are canceled. Only finished jobs are pruned, so the running job
+	// is retained along with the newest maxFinishedJobs canceled jobs.
+	running := q.submit([]byte("running"))
+	<-printer.started
+
+	const extra = 5
+	var ids []string
+	for i := 0; i < maxFinishedJobs+extra; i++ {
+		j := q.submit(nil)
+		if _, err := q.cancel(j.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, j.ID)
+	}
+
+	if jobs := q.list(); len(jobs) != maxFinishedJobs+1 || jobs[0].ID != running.ID {
+		t.Fatalf("expected the running job and %v finished jobs, got %v jobs", maxFinishedJobs, len(jobs))
+	}
+	for _, id := range ids[:extra] {
+		if _, err := q.get(id); err != errJobNotFound {
+			t.Fatalf("expected job %v to be pruned, got %v", id, err)
+		}
+	}
+	for _, id := range ids[extra:] {
+		if _, err := q.get(id); err != nil {
+			t.Fatalf("expected job %v to be retained, got %v", id, err)
+		}
+	}
+
+	// Once the running job finishes, it is the oldest finished job and is pruned in turn.
+	printer.release <- nil
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := q.get(running.ID); err == errJobNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the running job to be pruned once it finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if jobs := q.list(); len(jobs) != maxFinishedJobs {
+		t.Fatalf("expected %v jobs, got %v", maxFinishedJobs, len(jobs))
+	}
+}
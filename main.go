@@ -3,60 +3,140 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
-
-	"github.com/tarm/serial"
+	"path/filepath"
 
 	"github.com/pgavlin/lilprinty/internal/markdown"
 	"github.com/pgavlin/lilprinty/internal/printer"
+	"github.com/pgavlin/lilprinty/internal/transport"
 )
 
 func main() {
-	var port, filePath, serveAddress, stylePath string
-	flag.StringVar(&port, "port", "", "the serial port to use for the printer")
+	var printerURI, port, filePath, serveAddress, stylePath, emulatePath, outputPath, profileName string
+	var queryStatus bool
+	var profile printer.Profile
+	config := printer.DefaultConfig
+	flag.StringVar(&profileName, "profile", "58mm", "the printer profile to use (58mm or 80mm)")
+	flag.IntVar(&profile.Width, "width", 0, "the width of the print head in dots, if different from the profile's")
+	flag.Float64Var(&profile.DPI, "dpi", 0, "the resolution of the print head in dots per inch, if different from the profile's")
+	flag.IntVar(&profile.LeftMargin, "left-margin", 0, "the number of dots to leave blank at the left edge of the paper")
+	flag.IntVar(&profile.RightMargin, "right-margin", 0, "the number of dots to leave blank at the right edge of the paper")
+	flag.StringVar(&printerURI, "printer", "", "the printer to use, e.g. serial:///dev/ttyUSB0?baud=19200&parity=none&stopbits=1, tcp://host:9100, or file:///path")
+	flag.StringVar(&port, "port", "", "the serial port to use for the printer (deprecated: use -printer)")
+	flag.BoolVar(&queryStatus, "status", false, "query the printer's status before printing")
+	flag.DurationVar(&config.DotPrintTime, "dot-print-time", config.DotPrintTime, "the time the printer takes to print a line of dots")
+	flag.DurationVar(&config.DotFeedTime, "dot-feed-time", config.DotFeedTime, "the time the printer takes to feed a line of dots")
+	flag.IntVar(&config.HeatingDots, "heating-dots", config.HeatingDots, "the maximum number of dots heated at once, in units of 8 dots less one")
+	flag.IntVar(&config.HeatTime, "heat-time", config.HeatTime, "the time each dot is heated, in units of 10µs")
+	flag.IntVar(&config.HeatInterval, "heat-interval", config.HeatInterval, "the time between heating groups of dots, in units of 10µs")
+	flag.IntVar(&config.PrintDensity, "print-density", config.PrintDensity, "the print density, in 5% increments over 50%")
+	flag.IntVar(&config.BreakTime, "break-time", config.BreakTime, "the print break time, in units of 250µs")
 	flag.StringVar(&stylePath, "style", "", "the path to the stylesheet, if any")
 	flag.StringVar(&filePath, "file", "", "the path to the file to print, if any")
 	flag.StringVar(&serveAddress, "serve", "", "the address to serve on, if any")
+	flag.StringVar(&emulatePath, "emulate", "", "the path to a captured printer stream to decode, if any ('-' for stdin)")
+	flag.StringVar(&outputPath, "output", "output.png", "the path to the PNG written by -emulate")
 	flag.Parse()
 
-	if filePath != "" && serveAddress != "" {
-		fmt.Fprintf(os.Stderr, "only one of -file and -serve may be specified")
+	modes := 0
+	for _, m := range []string{filePath, serveAddress, emulatePath} {
+		if m != "" {
+			modes++
+		}
+	}
+	if modes > 1 {
+		fmt.Fprintf(os.Stderr, "only one of -file, -serve, and -emulate may be specified")
 		os.Exit(-1)
 	}
 
-	var w io.Writer
-	if port != "" {
-		s, err := serial.OpenPort(&serial.Config{Name: port, Baud: 9600})
-		if err != nil {
-			log.Fatalf("error opening '%v': %v", port, err)
+	base, ok := printer.Profiles[profileName]
+	if !ok {
+		log.Fatalf("unknown printer profile '%v'", profileName)
+	}
+	// Start from the named profile and apply only the geometry flags that were given, so that a margin can be
+	// explicitly set to zero.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "width":
+			base.Width = profile.Width
+		case "dpi":
+			base.DPI = profile.DPI
+		case "left-margin":
+			base.LeftMargin = profile.LeftMargin
+		case "right-margin":
+			base.RightMargin = profile.RightMargin
 		}
-		w = s
-	} else {
-		w = os.Stdout
+	})
+	profile = base
+	if err := profile.Validate(); err != nil {
+		log.Fatalf("invalid printer profile: %v", err)
+	}
+	config.Profile = profile
+
+	if emulatePath != "" {
+		if err := emulate(emulatePath, outputPath, profile.Width); err != nil {
+			log.Fatalf("error emulating '%v': %v", emulatePath, err)
+		}
+		return
 	}
 
-	style := defaultStyle
+	style := defaultStyle(profile.DPI)
 	if stylePath != "" {
-		s, err := loadStylesheet(stylePath)
+		s, err := loadStylesheet(stylePath, profile.DPI)
 		if err != nil {
 			log.Fatalf("error loading style sheet: %v", err)
 		}
 		style = s
 	}
 
+	if port != "" {
+		if printerURI != "" {
+			log.Fatalf("only one of -port and -printer may be specified")
+		}
+		printerURI = (&url.URL{Scheme: "serial", Path: port}).String()
+	}
+
+	var device *printer.Device
+	if printerURI != "" {
+		conn, err := transport.Open(printerURI)
+		if err != nil {
+			log.Fatalf("error opening '%v': %v", printerURI, err)
+		}
+		defer conn.Close()
+
+		config.BaudRate, config.BitsPerByte = conn.BaudRate(), conn.BitsPerByte()
+		if queryStatus {
+			device = printer.NewReadWriter(conn, config)
+		} else {
+			device = printer.New(conn, config)
+		}
+		conn.OnReconnect = device.Reinitialize
+	} else {
+		// Output written to stdout is not consumed by a printer, so there is no need to pace it.
+		config.BaudRate, config.DotPrintTime, config.DotFeedTime = 0, 0, 0
+		device = printer.New(os.Stdout, config)
+	}
+	if err := device.Initialize(); err != nil {
+		log.Fatalf("error initializing printer: %v", err)
+	}
+
 	if filePath != "" {
 		bytes, err := ioutil.ReadFile(filePath)
 		if err != nil {
 			log.Fatalf("error reading '%v': %v", filePath, err)
 		}
-		if err = markdown.Render(printer.New(w), bytes, style.proportionalFamily, style.monospaceFamily, style.headingStyles, style.paragraphStyle); err != nil {
+		if err = device.CheckStatus(); err != nil {
+			log.Fatalf("printer is not ready: %v", err)
+		}
+		style.BaseDir = filepath.Dir(filePath)
+		if err = markdown.Render(device, bytes, style); err != nil {
 			log.Fatalf("error rendering document: %v", err)
 		}
 	} else {
-		if err := serve(serveAddress, style, w); err != nil {
+		if err := serve(serveAddress, style, device); err != nil {
 			log.Fatalf("serve error: %v", err)
 		}
 	}
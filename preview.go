@@ -7,29 +7,41 @@ import (
 	"sort"
 
 	"github.com/pgavlin/lilprinty/internal/bitmap"
+	"github.com/pgavlin/lilprinty/internal/printer"
 )
 
+// previewBorder is the width of the border drawn on either side of the paper in a preview.
+const previewBorder = 40
+
 type slice struct {
 	contents *bitmap.Image
 	yOrigin  int
+
+	// barcode is the native barcode drawn by the slice's contents, if any.
+	barcode *bitmap.Barcode
 }
 
 type preview struct {
-	slices []slice
-	height int
+	profile printer.Profile
+	slices  []slice
+	height  int
 }
 
-func (preview) MaxWidth() int {
-	return 384
+func newPreview(profile printer.Profile) *preview {
+	return &preview{profile: profile}
 }
 
-func (preview) DPI() float64 {
-	return 203.2
+func (p *preview) MaxWidth() int {
+	return p.profile.PrintableWidth()
+}
+
+func (p *preview) DPI() float64 {
+	return p.profile.DPI
 }
 
 func (p *preview) PrintBitmap(img *bitmap.Image) error {
-	if img.Bounds().Dx() > 384 {
-		return fmt.Errorf("bitmap must be less than 384 pixels wide")
+	if img.Bounds().Dx() > p.MaxWidth() {
+		return fmt.Errorf("bitmap must be less than %d pixels wide", p.MaxWidth())
 	}
 	p.slices = append(p.slices, slice{
 		contents: img,
@@ -39,6 +51,20 @@ func (p *preview) PrintBitmap(img *bitmap.Image) error {
 	return nil
 }
 
+// SupportsBarcode returns true. The preview displays each barcode's raster form, but records the barcode so that
+// replay can print it natively if the target device supports it.
+func (p *preview) SupportsBarcode(b bitmap.Barcode) bool {
+	return true
+}
+
+func (p *preview) PrintBarcode(b bitmap.Barcode) error {
+	if err := p.PrintBitmap(b.Raster); err != nil {
+		return err
+	}
+	p.slices[len(p.slices)-1].barcode = &b
+	return nil
+}
+
 func (p *preview) Feed(lines int) error {
 	p.height += lines
 	return nil
@@ -49,16 +75,16 @@ func (p *preview) ColorModel() color.Model {
 }
 
 func (p *preview) Bounds() image.Rectangle {
-	return image.Rect(0, 0, 384+80, p.height)
+	return image.Rect(0, 0, p.profile.Width+2*previewBorder, p.height)
 }
 
 func (p *preview) At(x, y int) color.Color {
-	if x < 0 || x >= 384+80 || y < 0 || y >= p.height {
+	if x < 0 || x >= p.profile.Width+2*previewBorder || y < 0 || y >= p.height {
 		return color.Black
 	}
 
-	x -= 40
-	if x < 0 || x >= 384 {
+	x -= previewBorder + p.profile.LeftMargin
+	if x < 0 || x >= p.MaxWidth() {
 		return color.White
 	}
 
@@ -81,3 +107,44 @@ func (p *preview) At(x, y int) color.Color {
 	}
 	return color.Black
 }
+
+// replay prints the preview's contents to the given device, stopping with errJobCanceled if canceled returns true
+// before the contents have been printed.
+func (p *preview) replay(device bitmap.Device, canceled func() bool) error {
+	feed := func(lines int) error {
+		for lines > 0 {
+			n := lines
+			if n > 255 {
+				n = 255
+			}
+			if err := device.Feed(n); err != nil {
+				return err
+			}
+			lines -= n
+		}
+		return nil
+	}
+
+	y := 0
+	for _, s := range p.slices {
+		if canceled() {
+			return errJobCanceled
+		}
+		if err := feed(s.yOrigin - y); err != nil {
+			return err
+		}
+		if err := replaySlice(device, s); err != nil {
+			return err
+		}
+		y = s.yOrigin + s.contents.Bounds().Dy()
+	}
+	return feed(p.height - y)
+}
+
+// replaySlice prints a single slice to the given device, printing its barcode natively if possible.
+func replaySlice(device bitmap.Device, s slice) error {
+	if native, ok := device.(bitmap.BarcodeDevice); ok && s.barcode != nil && native.SupportsBarcode(*s.barcode) {
+		return native.PrintBarcode(*s.barcode)
+	}
+	return device.PrintBitmap(s.contents)
+}
@@ -1,20 +1,65 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"image/png"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/pgavlin/lilprinty/internal/bitmap"
 	"github.com/pgavlin/lilprinty/internal/markdown"
 	"github.com/pgavlin/lilprinty/internal/printer"
+	"github.com/pgavlin/lilprinty/internal/shortener"
 )
 
 type server struct {
-	defaultStyle style
-	printer      *printer.Device
+	defaultStyle markdown.Style
+	jobs         *jobQueue
+
+	// renderLock serializes rendering, as font faces are not safe for concurrent use.
+	renderLock sync.Mutex
+
+	// printerLock serializes access to the printer between the job queue and status queries.
+	printerLock sync.Mutex
+	printer     *printer.Device
+}
+
+func (s *server) render(device bitmap.Device, contents []byte) error {
+	s.renderLock.Lock()
+	defer s.renderLock.Unlock()
+
+	return markdown.Render(device, contents, s.defaultStyle)
+}
+
+// printJob renders a job's contents and prints the result.
+func (s *server) printJob(j *job, canceled func() bool) error {
+	preview := newPreview(s.printer.Profile())
+	if err := s.render(preview, j.contents); err != nil {
+		log.Printf("error rendering job %v: %v", j.ID, err)
+		return fmt.Errorf("rendering document: %w", err)
+	}
+	s.jobs.setHeight(j, preview.height)
+
+	s.printerLock.Lock()
+	defer s.printerLock.Unlock()
+
+	if err := s.printer.CheckStatus(); err != nil {
+		log.Printf("printer is not ready for job %v: %v", j.ID, err)
+		return fmt.Errorf("printing document: %w", err)
+	}
+	if err := preview.replay(s.printer, canceled); err != nil {
+		if err == errJobCanceled {
+			return err
+		}
+		log.Printf("error printing job %v: %v", j.ID, err)
+		return fmt.Errorf("printing document: %w", err)
+	}
+	return nil
 }
 
 func (s *server) handlePrint(w http.ResponseWriter, req *http.Request) {
@@ -32,39 +77,123 @@ func (s *server) handlePrint(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	device, preview := bitmap.Device(s.printer), preview{}
-	if isPreview {
-		device = &preview
+	if !isPreview {
+		j := s.jobs.submit(contents)
+		w.Header().Add("Location", "/jobs/"+j.ID)
+		writeJSON(w, http.StatusAccepted, j)
+		return
 	}
 
-	if err = markdown.Render(device, contents, s.defaultStyle.proportionalFamily, s.defaultStyle.monospaceFamily, s.defaultStyle.headingStyles, s.defaultStyle.paragraphStyle); err != nil {
+	preview := newPreview(s.printer.Profile())
+	if err = s.render(preview, contents); err != nil {
 		log.Printf("error rendering content: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if !isPreview {
-		w.WriteHeader(http.StatusOK)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Add("Content-Type", "image/png")
-	if err = png.Encode(w, &preview); err != nil {
+	if err = png.Encode(w, preview); err != nil {
 		log.Printf("error encoding preview result: %v", err)
 	}
 }
 
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("error encoding response: %v", err)
+	}
+}
+
+func (s *server) handleJobs(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.jobs.list())
+}
+
+func (s *server) handleJob(w http.ResponseWriter, req *http.Request) {
+	id := strings.TrimPrefix(req.URL.Path, "/jobs/")
+
+	var j job
+	var err error
+	switch req.Method {
+	case http.MethodGet:
+		j, err = s.jobs.get(id)
+	case http.MethodDelete:
+		j, err = s.jobs.cancel(id)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, j)
+	case errJobNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errJobFinished:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *server) handleStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.printerLock.Lock()
+	status, err := s.printer.Status()
+	s.printerLock.Unlock()
+
+	switch {
+	case err == printer.ErrStatusUnsupported:
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	case err != nil:
+		log.Printf("error querying printer status: %v", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
 func serveFile(path string) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		http.ServeFile(w, req, path)
 	}
 }
 
-func serve(address string, defaultStyle style, w io.Writer) error {
+func serve(address string, defaultStyle markdown.Style, printer *printer.Device) error {
 	server := &server{
 		defaultStyle: defaultStyle,
-		printer:      printer.New(w),
+		printer:      printer,
 	}
+	server.jobs = newJobQueue(server.printJob)
+
 	http.HandleFunc("/print", server.handlePrint)
+	http.HandleFunc("/jobs", server.handleJobs)
+	http.HandleFunc("/jobs/", server.handleJob)
+	http.HandleFunc("/status", server.handleStatus)
+
+	// Serve redirects for links shortened by the local shortener.
+	if local, ok := defaultStyle.Shortener.(*shortener.Local); ok {
+		base, err := url.Parse(local.BaseURL())
+		if err != nil {
+			return fmt.Errorf("parsing link base URL: %w", err)
+		}
+		prefix := base.Path[:strings.LastIndex(base.Path, "/")+1]
+		if prefix == "" || prefix == "/" {
+			return fmt.Errorf("link base URL '%v' must include a path, e.g. /l/", local.BaseURL())
+		}
+		http.Handle(prefix, local)
+	}
+
 	http.HandleFunc("/", serveFile("./index.html"))
 	http.HandleFunc("/index.css", serveFile("./index.css"))
 	http.HandleFunc("/index.js", serveFile("./index.js"))
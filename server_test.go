@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pgavlin/lilprinty/internal/printer"
+)
+
+// statusLink is a fake printer link that answers real-time status queries. The printer and error status queries report
+// that all is well, and the paper status query reports the given paper status. If silent is true, queries go
+// unanswered.
+type statusLink struct {
+	paperStatus byte
+	silent      bool
+	responses   []byte
+}
+
+func (l *statusLink) Write(b []byte) (int, error) {
+	if len(b) == 3 && b[0] == 0x10 && b[1] == 0x04 && !l.silent {
+		// Bits 1 and 4 of every status response are set. Query 4 is the paper status query.
+		response := byte(0x12)
+		if b[2] == 4 {
+			response |= l.paperStatus
+		}
+		l.responses = append(l.responses, response)
+	}
+	return len(b), nil
+}
+
+func (l *statusLink) Read(b []byte) (int, error) {
+	if len(l.responses) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, l.responses)
+	l.responses = l.responses[n:]
+	return n, nil
+}
+
+// newTestServer creates a server whose jobs are held by the given fake printer. If link is nil, the server's printer
+// is write-only.
+func newTestServer(p *blockingPrinter, link io.ReadWriter) *server {
+	config := printer.DefaultConfig
+	config.BaudRate, config.DotPrintTime, config.DotFeedTime = 0, 0, 0
+
+	s := &server{defaultStyle: defaultStyle(config.Profile.DPI)}
+	if link == nil {
+		s.printer = printer.New(ioutil.Discard, config)
+	} else {
+		s.printer = printer.NewReadWriter(link, config)
+	}
+	s.jobs = newJobQueue(p.print)
+	return s
+}
+
+// serveRequest sends a request to the given handler and returns the recorded response.
+func serveRequest(handler http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+// decodeJSON decodes the JSON body of the given response into value.
+func decodeJSON(t *testing.T, w *httptest.ResponseRecorder, value interface{}) {
+	t.Helper()
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("expected a JSON response, got %q", contentType)
+	}
+	if err := json.NewDecoder(w.Body).Decode(value); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+}
+
+func TestHandlePrint(t *testing.T) {
+	p := newBlockingPrinter()
+	s := newTestServer(p, nil)
+
+	w := serveRequest(s.handlePrint, http.MethodPost, "/print", "# Hello")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %v, got %v", http.StatusAccepted, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "/jobs/1" {
+		t.Fatalf("expected location /jobs/1, got %q", location)
+	}
+	var j job
+	decodeJSON(t, w, &j)
+	if j.ID != "1" || j.State != jobQueued || j.Submitted.IsZero() {
+		t.Fatalf("unexpected job %+v", j)
+	}
+	if contents := <-p.started; contents != "# Hello" {
+		t.Fatalf("expected the submitted document to print, got %q", contents)
+	}
+
+	// Previews are rendered immediately and are not queued.
+	w = serveRequest(s.handlePrint, http.MethodPost, "/print?preview=1", "# Hello")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v", http.StatusOK, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "image/png" {
+		t.Fatalf("expected a PNG response, got %q", contentType)
+	}
+	if _, err := png.Decode(w.Body); err != nil {
+		t.Fatalf("decoding preview: %v", err)
+	}
+	if jobs := s.jobs.list(); len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %v", len(jobs))
+	}
+
+	if w = serveRequest(s.handlePrint, http.MethodGet, "/print", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %v, got %v", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHandleJobs(t *testing.T) {
+	p := newBlockingPrinter()
+	s := newTestServer(p, nil)
+
+	first, second := s.jobs.submit([]byte("first")), s.jobs.submit([]byte("second"))
+	<-p.started
+
+	// The list holds every job in submission order.
+	w := serveRequest(s.handleJobs, http.MethodGet, "/jobs", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v", http.StatusOK, w.Code)
+	}
+	var jobs []job
+	decodeJSON(t, w, &jobs)
+	if len(jobs) != 2 || jobs[0].ID != first.ID || jobs[1].ID != second.ID || jobs[1].State != jobQueued {
+		t.Fatalf("unexpected jobs %+v", jobs)
+	}
+
+	if w = serveRequest(s.handleJobs, http.MethodPost, "/jobs", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %v, got %v", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHandleJob(t *testing.T) {
+	p := newBlockingPrinter()
+	s := newTestServer(p, nil)
+
+	running, queued := s.jobs.submit([]byte("running")), s.jobs.submit([]byte("queued"))
+	<-p.started
+
+	cases := []struct {
+		name, method, id string
+		status           int
+		state            jobState
+	}{
+		{name: "get running", method: http.MethodGet, id: running.ID, status: http.StatusOK, state: jobPrinting},
+		{name: "get queued", method: http.MethodGet, id: queued.ID, status: http.StatusOK, state: jobQueued},
+		{name: "get unknown", method: http.MethodGet, id: "99", status: http.StatusNotFound},
+		{name: "cancel queued", method: http.MethodDelete, id: queued.ID, status: http.StatusOK, state: jobCanceled},
+		{name: "cancel finished", method: http.MethodDelete, id: queued.ID, status: http.StatusConflict},
+		{name: "cancel running", method: http.MethodDelete, id: running.ID, status: http.StatusOK, state: jobPrinting},
+		{name: "cancel unknown", method: http.MethodDelete, id: "99", status: http.StatusNotFound},
+		{name: "unsupported method", method: http.MethodPut, id: running.ID, status: http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := serveRequest(s.handleJob, c.method, "/jobs/"+c.id, "")
+			if w.Code != c.status {
+				t.Fatalf("expected status %v, got %v", c.status, w.Code)
+			}
+			if c.status != http.StatusOK {
+				return
+			}
+
+			var j job
+			decodeJSON(t, w, &j)
+			if j.ID != c.id || j.State != c.state {
+				t.Fatalf("expected job %v to be %v, got %+v", c.id, c.state, j)
+			}
+		})
+	}
+
+	// The canceled running job stops once the printer notices.
+	waitForState(t, s.jobs, running.ID, jobCanceled)
+}
+
+func TestHandleStatus(t *testing.T) {
+	cases := []struct {
+		name, method string
+		link         *statusLink
+		status       int
+		expected     printer.Status
+	}{
+		{name: "ready", method: http.MethodGet, link: &statusLink{}, status: http.StatusOK,
+			expected: printer.Status{Online: true}},
+		{name: "paper out", method: http.MethodGet, link: &statusLink{paperStatus: 3 << 5}, status: http.StatusOK,
+			expected: printer.Status{Online: true, PaperOut: true}},
+		{name: "no response", method: http.MethodGet, link: &statusLink{silent: true}, status: http.StatusServiceUnavailable},
+		{name: "write-only", method: http.MethodGet, status: http.StatusNotImplemented},
+		{name: "unsupported method", method: http.MethodPost, link: &statusLink{},
+			status: http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var link io.ReadWriter
+			if c.link != nil {
+				link = c.link
+			}
+			s := newTestServer(newBlockingPrinter(), link)
+
+			w := serveRequest(s.handleStatus, c.method, "/status", "")
+			if w.Code != c.status {
+				t.Fatalf("expected status %v, got %v", c.status, w.Code)
+			}
+			if c.status != http.StatusOK {
+				return
+			}
+
+			var status printer.Status
+			decodeJSON(t, w, &status)
+			if status != c.expected {
+				t.Fatalf("expected %+v, got %+v", c.expected, status)
+			}
+		})
+	}
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/golang/freetype/truetype"
-	woff "github.com/tdewolff/canvas/font"
 	"golang.org/x/image/font/gofont/gobold"
 	"golang.org/x/image/font/gofont/gobolditalic"
 	"golang.org/x/image/font/gofont/goitalic"
@@ -17,11 +19,22 @@ import (
 	"golang.org/x/image/font/gofont/goregular"
 
 	"github.com/pgavlin/lilprinty/internal/font"
+	"github.com/pgavlin/lilprinty/internal/highlight"
+	"github.com/pgavlin/lilprinty/internal/hyphen"
 	"github.com/pgavlin/lilprinty/internal/markdown"
+	"github.com/pgavlin/lilprinty/internal/shortener"
 	"github.com/pgavlin/lilprinty/internal/util"
 )
 
+// fontFamily describes the typefaces of a font family. Each typeface may be given as an http(s) URL, a file:// URL or
+// path to a font file, or the full name of an installed font, e.g. "DejaVu Sans Bold". TrueType, OpenType, WOFF, and
+// WOFF2 fonts are supported.
 type fontFamily struct {
+	// Family is the name of an installed font family, e.g. "DejaVu Sans". Its styles are used for any typefaces that
+	// are not given explicitly.
+	Family string `json:"family,omitempty"`
+	// Regular, Bold, Italic, and BoldItalic are the locations of the family's typefaces: http(s) URLs, paths, which are
+	// relative to the stylesheet, or the names of installed fonts.
 	Regular    string `json:"regular,omitempty"`
 	Bold       string `json:"bold,omitempty"`
 	Italic     string `json:"italic,omitempty"`
@@ -32,20 +45,118 @@ type blockStyle struct {
 	PointSize    float64 `json:"pointSize,omitempty"`
 	TopMargin    float64 `json:"topMargin,omitempty"`
 	BottomMargin float64 `json:"bottomMargin,omitempty"`
+	// Align selects the alignment of the block's lines: "left" (the default), "center", "right", or "justify".
+	Align string `json:"align,omitempty"`
+	// LineHeight is the height of each line as a multiple of its natural height, e.g. 1.2.
+	LineHeight float64 `json:"lineHeight,omitempty"`
+	// LineHeightPoints is the height of each line in points. It takes precedence over LineHeight.
+	LineHeightPoints float64 `json:"lineHeightPoints,omitempty"`
+	// Tracking is the extra space in points that follows each character.
+	Tracking float64 `json:"tracking,omitempty"`
 }
 
-type styleSheet struct {
-	ProportionalFamily *fontFamily  `json:"proportionalFamily,omitempty"`
-	MonospaceFamily    *fontFamily  `json:"monospaceFamily,omitempty"`
-	HeadingStyles      []blockStyle `json:"headingStyles,omitEmpty"`
-	ParagraphStyle     *blockStyle  `json:"paragraphStyle,omitEmpty"`
+type linkStyle struct {
+	// Shortener selects how link URLs are shortened before they are encoded: "none" (the default) encodes them in full,
+	// "tinyurl" sends them to tinyurl.com, and "local" assigns them IDs served by this process.
+	Shortener string `json:"shortener,omitempty"`
+	// BaseURL is the base URL of links shortened by the local shortener, e.g. "http://printer.local:8080/l/".
+	BaseURL string `json:"baseURL,omitempty"`
+	// Store is the path to the file used to persist links shortened by the local shortener, if any. Relative paths are
+	// resolved against the directory that contains the stylesheet.
+	Store string `json:"store,omitempty"`
+	// Code selects the barcode used to encode links: "datamatrix" (the default), "qr", "qr:L", "qr:M", "qr:Q",
+	// "qr:H", "aztec", or "none". Individual links may override the code using their title or URL fragment.
+	Code string `json:"code,omitempty"`
+	// PrintURL prints each link's URL in monospace beneath its code.
+	PrintURL bool `json:"printURL,omitempty"`
+	// Height is the smallest height of each link code in points. By default, link codes are at least as tall as the
+	// surrounding text.
+	Height float64 `json:"height,omitempty"`
+	// ModuleSize is the smallest size of each module of a link code in dots. The default is 2.
+	ModuleSize int `json:"moduleSize,omitempty"`
+}
+
+type codeBlockStyle struct {
+	// The block style of code blocks. If it is omitted, code blocks use the paragraph style.
+	blockStyle
+
+	// Overflow selects how lines that are too wide for the paper are printed: "wrap" (the default) breaks them,
+	// "shrink" reduces the point size of the block until they fit, "rotate" prints the block sideways, and "clip" cuts
+	// them off.
+	Overflow string `json:"overflow,omitempty"`
+	// MinPointSize is the smallest point size used by the "shrink" policy.
+	MinPointSize float64 `json:"minPointSize,omitempty"`
+}
+
+type inlineCodeStyle struct {
+	// Scale is the point size of code spans relative to the surrounding text.
+	Scale float64 `json:"scale,omitempty"`
+	// Style is a space-separated list of text styles added to code spans: "bold", "italic", "underline", or "invert".
+	Style string `json:"style,omitempty"`
+}
+
+type blockquoteStyle struct {
+	// Indent is the indent of the blockquote's contents in points. Defaults to 4.5.
+	Indent *float64 `json:"indent,omitempty"`
+	// RuleThickness is the thickness of the rule to the left of the blockquote in points. Rules are at least one dot
+	// thick.
+	RuleThickness *float64 `json:"ruleThickness,omitempty"`
+	// RuleStyle is the style of the rule: "solid" (the default), "dashed", "dotted", "double", or "none".
+	RuleStyle string `json:"ruleStyle,omitempty"`
+}
+
+type listStyle struct {
+	// Indent is the indent of each list item's marker in points.
+	Indent float64 `json:"indent,omitempty"`
+	// MarkerGap is the space between each list item's marker and its contents in points.
+	MarkerGap float64 `json:"markerGap,omitempty"`
+	// Bullets holds the marker for unordered list items at each level of nesting, e.g. ["•", "◦", "▪"].
+	Bullets []string `json:"bullets,omitempty"`
+	// Ordered holds the marker format for ordered list items at each level of nesting, e.g. ["1.", "a)", "i."].
+	Ordered []string `json:"ordered,omitempty"`
+}
+
+type thematicBreakStyle struct {
+	// Thickness is the thickness of the rule in points.
+	Thickness float64 `json:"thickness,omitempty"`
+	// Style is the style of the rule: "solid" (the default), "dashed", "dotted", "double", or "none".
+	Style string `json:"style,omitempty"`
+	// Margin is the space above and below the rule in points. Defaults to half the paragraph point size.
+	Margin *float64 `json:"margin,omitempty"`
 }
 
-type style struct {
-	proportionalFamily *font.Family
-	monospaceFamily    *font.Family
-	headingStyles      []markdown.BlockStyle
-	paragraphStyle     markdown.BlockStyle
+type hyphenationStyle struct {
+	// Patterns is the path or URL of a file of TeX hyphenation patterns and exceptions, such as those distributed by
+	// the hyph-utf8 project. Relative paths are resolved against the directory that contains the stylesheet. If empty,
+	// the bundled English patterns are used.
+	Patterns string `json:"patterns,omitempty"`
+	// LeftMin and RightMin are the minimum number of letters that must precede and follow a hyphen, respectively.
+	LeftMin  int `json:"leftMin,omitempty"`
+	RightMin int `json:"rightMin,omitempty"`
+}
+
+// highlightStyle maps token kinds ("keyword", "comment", "string", "number", or "plain") to space-separated lists of
+// text styles: "bold", "italic", "underline", "invert", or "none". Kinds that are not listed keep their default style.
+type highlightStyle map[string]string
+
+type styleSheet struct {
+	ProportionalFamily *fontFamily `json:"proportionalFamily,omitempty"`
+	MonospaceFamily    *fontFamily `json:"monospaceFamily,omitempty"`
+	// FallbackFamilies are consulted in order for characters that the proportional or monospace family lacks.
+	FallbackFamilies []*fontFamily       `json:"fallbackFamilies,omitempty"`
+	HeadingStyles    []blockStyle        `json:"headingStyles,omitEmpty"`
+	ParagraphStyle   *blockStyle         `json:"paragraphStyle,omitEmpty"`
+	Links            *linkStyle          `json:"links,omitempty"`
+	Highlight        highlightStyle      `json:"highlight,omitempty"`
+	CodeBlock        *codeBlockStyle     `json:"codeBlock,omitempty"`
+	Hyphenation      *hyphenationStyle   `json:"hyphenation,omitempty"`
+	InlineCode       *inlineCodeStyle    `json:"inlineCode,omitempty"`
+	Blockquote       *blockquoteStyle    `json:"blockquote,omitempty"`
+	List             *listStyle          `json:"list,omitempty"`
+	ThematicBreak    *thematicBreakStyle `json:"thematicBreak,omitempty"`
+	// FontCache is the directory in which downloaded fonts are cached. Defaults to a "lilprinty/fonts" directory in
+	// the user's cache directory.
+	FontCache string `json:"fontCache,omitempty"`
 }
 
 func mustParseFontFamily(regular, bold, italic, boldItalic []byte, options truetype.Options) *font.Family {
@@ -56,69 +167,165 @@ func mustParseFontFamily(regular, bold, italic, boldItalic []byte, options truet
 	return family
 }
 
-var fontOptions = truetype.Options{DPI: 203.2, SubPixelsX: 1}
+// fontOptions returns the options used to rasterize fonts for a device with the given DPI.
+func fontOptions(dpi float64) truetype.Options {
+	return truetype.Options{DPI: dpi, SubPixelsX: 1}
+}
 
-var defaultStyle = style{
-	proportionalFamily: mustParseFontFamily(goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF, fontOptions),
-	monospaceFamily:    mustParseFontFamily(gomono.TTF, gomonobold.TTF, gomonoitalic.TTF, gomonobolditalic.TTF, fontOptions),
-	headingStyles: []markdown.BlockStyle{
-		{PointSize: 16.0, TopMargin: 3.2, BottomMargin: 1.6},
-		{PointSize: 14.0, TopMargin: 2.8, BottomMargin: 1.4},
-		{PointSize: 12.0, TopMargin: 2.4, BottomMargin: 1.2},
-		{PointSize: 10.0, TopMargin: 2.0, BottomMargin: 1.0},
-	},
-	paragraphStyle: markdown.BlockStyle{PointSize: 8.0, TopMargin: 1.6, BottomMargin: 0.8},
+// defaultStyle returns the default style for a device with the given DPI.
+func defaultStyle(dpi float64) markdown.Style {
+	options := fontOptions(dpi)
+	return markdown.Style{
+		ProportionalFamily: mustParseFontFamily(goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF, options),
+		MonospaceFamily:    mustParseFontFamily(gomono.TTF, gomonobold.TTF, gomonoitalic.TTF, gomonobolditalic.TTF, options),
+		HeadingStyles: []markdown.BlockStyle{
+			{PointSize: 16.0, TopMargin: 3.2, BottomMargin: 1.6},
+			{PointSize: 14.0, TopMargin: 2.8, BottomMargin: 1.4},
+			{PointSize: 12.0, TopMargin: 2.4, BottomMargin: 1.2},
+			{PointSize: 10.0, TopMargin: 2.0, BottomMargin: 1.0},
+		},
+		ParagraphStyle:   markdown.BlockStyle{PointSize: 8.0, TopMargin: 1.6, BottomMargin: 0.8},
+		Shortener:        shortener.None{},
+		LinkCode:         markdown.DefaultLinkCode,
+		Highlight:        markdown.DefaultHighlight,
+		CodeOverflow:     markdown.CodeOverflowWrap,
+		MinCodePointSize: markdown.DefaultMinCodePointSize,
+		Hyphenation:      hyphen.English,
+		Blockquote:       markdown.DefaultBlockquoteStyle,
+		List:             markdown.DefaultListStyle,
+	}
 }
 
-func loadTTF(url string) ([]byte, error) {
-	bytes, _, err := util.DownloadFile(url)
+// defaultFontCache returns the default directory for cached font downloads, or the empty string if the user has no
+// cache directory.
+func defaultFontCache() string {
+	dir, err := os.UserCacheDir()
 	if err != nil {
-		return nil, err
+		return ""
+	}
+	return filepath.Join(dir, "lilprinty", "fonts")
+}
+
+// fontExtensions holds the extensions of the font file formats that can be loaded.
+var fontExtensions = map[string]bool{".ttf": true, ".otf": true, ".ttc": true, ".otc": true, ".woff": true, ".woff2": true}
+
+// loadTypeface loads the typeface at the given location, which may be an http(s) URL, a file:// URL or path to a font
+// file, or the name of an installed font. Relative paths are resolved against the given base directory, which is
+// typically the directory that contains the stylesheet. Downloaded fonts are cached in the given directory.
+func loadTypeface(location, baseDir, cacheDir string) (*font.Typeface, error) {
+	u, err := url.Parse(location)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		contents, err := util.DownloadCachedFile(location, cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		return font.ParseTypeface(contents, 0)
+	}
+
+	// Anything that is a file URL, looks like a path, or has a font file extension is loaded from disk so that a
+	// missing file is reported as such rather than as a missing font.
+	path := location
+	if err != nil || u.Scheme != "file" {
+		if !filepath.IsAbs(path) && baseDir != "" {
+			path = filepath.Join(baseDir, path)
+		}
 	}
+	_, statErr := os.Stat(path)
+	if statErr == nil || (err == nil && u.Scheme == "file") || strings.ContainsAny(location, `/\`) ||
+		fontExtensions[strings.ToLower(filepath.Ext(location))] {
 
-	return woff.ToSFNT(bytes)
+		contents, _, err := util.LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return font.ParseTypeface(contents, 0)
+	}
+
+	systemFont, err := font.FindSystemFont(location)
+	if err != nil {
+		return nil, err
+	}
+	return systemFont.Load()
 }
 
-func loadFontFamily(family *fontFamily, defaults *font.Family) (*font.Family, error) {
+func loadFontFamily(family *fontFamily, defaults *font.Family, dpi float64, baseDir, cacheDir string) (*font.Family, error) {
 	if family == nil {
 		return defaults, nil
 	}
 
-	if family.Regular == "" {
-		return nil, fmt.Errorf("font family must specify a regular typeface")
+	// load loads the typeface at the given location, if any, or else the given style of the named family, if any.
+	load := func(location string, bold, italic bool) (*font.Typeface, error) {
+		if location != "" {
+			return loadTypeface(location, baseDir, cacheDir)
+		}
+		if family.Family != "" {
+			if systemFont := font.FindSystemFamilyStyle(family.Family, bold, italic); systemFont != nil {
+				return systemFont.Load()
+			}
+		}
+		return nil, nil
 	}
 
-	regular, err := loadTTF(family.Regular)
+	regular, err := load(family.Regular, false, false)
 	if err != nil {
 		return nil, fmt.Errorf("error loading regular typeface: %v", err)
 	}
+	if regular == nil {
+		if family.Family != "" {
+			return nil, fmt.Errorf("no installed font family named %q has a regular typeface", family.Family)
+		}
+		return nil, fmt.Errorf("font family must specify a regular typeface")
+	}
 
-	bold, italic, boldItalic := regular, regular, regular
+	bold, err := load(family.Bold, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("error loading bold typeface: %v", err)
+	}
+	italic, err := load(family.Italic, false, true)
+	if err != nil {
+		return nil, fmt.Errorf("error loading italic typeface: %v", err)
+	}
+	boldItalic, err := load(family.BoldItalic, true, true)
+	if err != nil {
+		return nil, fmt.Errorf("error loading boldItalic typeface: %v", err)
+	}
 
-	if family.Bold != "" {
-		if bold, err = loadTTF(family.Bold); err != nil {
-			return nil, fmt.Errorf("error loading bold typeface: %v", err)
-		}
+	if bold == nil {
+		bold = regular
 	}
-	if family.Italic != "" {
-		if italic, err = loadTTF(family.Italic); err != nil {
-			return nil, fmt.Errorf("error loading italic typeface: %v", err)
-		}
+	if italic == nil {
+		italic = regular
 	}
-	if family.BoldItalic != "" {
-		if boldItalic, err = loadTTF(family.BoldItalic); err != nil {
-			return nil, fmt.Errorf("error loading boldItalic typeface: %v", err)
-		}
+	if boldItalic == nil {
+		boldItalic = regular
 	}
 
-	return font.ParseFamily(regular, bold, italic, boldItalic, fontOptions)
+	return font.NewFamily(regular, bold, italic, boldItalic, fontOptions(dpi)), nil
 }
 
-func loadBlockStyle(style blockStyle) markdown.BlockStyle {
+func loadBlockStyle(style blockStyle) (markdown.BlockStyle, error) {
 	result := markdown.BlockStyle{
-		PointSize:    style.PointSize,
-		TopMargin:    style.TopMargin,
-		BottomMargin: style.BottomMargin,
+		PointSize:        style.PointSize,
+		TopMargin:        style.TopMargin,
+		BottomMargin:     style.BottomMargin,
+		LineHeight:       style.LineHeight,
+		LineHeightPoints: style.LineHeightPoints,
+		Tracking:         style.Tracking,
+	}
+	if result.LineHeight < 0 || result.LineHeightPoints < 0 {
+		return markdown.BlockStyle{}, fmt.Errorf("line height must not be negative")
+	}
+	switch style.Align {
+	case "", "left":
+		result.Align = markdown.AlignLeft
+	case "center":
+		result.Align = markdown.AlignCenter
+	case "right":
+		result.Align = markdown.AlignRight
+	case "justify":
+		result.Align = markdown.AlignJustify
+	default:
+		return markdown.BlockStyle{}, fmt.Errorf("unknown alignment '%v'", style.Align)
 	}
 	if result.PointSize == 0 {
 		result.PointSize = 10.0
@@ -129,48 +336,305 @@ func loadBlockStyle(style blockStyle) markdown.BlockStyle {
 	if result.BottomMargin == 0 {
 		result.BottomMargin = result.PointSize * 0.1
 	}
-	return result
+	return result, nil
+}
+
+// resolvePath resolves a path given in a stylesheet against the given base directory, which is typically the directory
+// that contains the stylesheet. Absolute paths and URLs are returned as-is.
+func resolvePath(location, baseDir string) string {
+	if location == "" || baseDir == "" || filepath.IsAbs(location) {
+		return location
+	}
+	// Windows drive letters parse as single-letter schemes, so treat those as paths as well.
+	if u, err := url.Parse(location); err == nil && len(u.Scheme) > 1 {
+		return location
+	}
+	return filepath.Join(baseDir, location)
+}
+
+func loadShortener(links *linkStyle, defaults shortener.Shortener, baseDir string) (shortener.Shortener, error) {
+	if links == nil {
+		return defaults, nil
+	}
+
+	switch links.Shortener {
+	case "":
+		return defaults, nil
+	case "tinyurl":
+		return shortener.TinyURL{}, nil
+	case "none":
+		return shortener.None{}, nil
+	case "local":
+		return shortener.NewLocal(links.BaseURL, resolvePath(links.Store, baseDir))
+	default:
+		return nil, fmt.Errorf("unknown link shortener '%v'", links.Shortener)
+	}
+}
+
+// parseTextStyle parses a space-separated list of text styles: "bold", "italic", "underline", "invert", or "none".
+func parseTextStyle(styles string) (markdown.TextStyle, error) {
+	var style markdown.TextStyle
+	for _, s := range strings.Fields(styles) {
+		switch s {
+		case "bold":
+			style.Bold = true
+		case "italic":
+			style.Italic = true
+		case "underline":
+			style.Underline = true
+		case "invert":
+			style.Invert = true
+		case "none":
+		default:
+			return markdown.TextStyle{}, fmt.Errorf("unknown text style '%v'", s)
+		}
+	}
+	return style, nil
+}
+
+func parseRuleStyle(style string) (markdown.RuleStyle, error) {
+	switch s := markdown.RuleStyle(style); s {
+	case "":
+		return markdown.RuleSolid, nil
+	case markdown.RuleSolid, markdown.RuleDashed, markdown.RuleDotted, markdown.RuleDouble, markdown.RuleNone:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown rule style '%v'", style)
+	}
+}
+
+func loadHighlight(spec highlightStyle, defaults map[highlight.Kind]markdown.TextStyle) (map[highlight.Kind]markdown.TextStyle, error) {
+	if len(spec) == 0 {
+		return defaults, nil
+	}
+
+	result := map[highlight.Kind]markdown.TextStyle{}
+	for kind, style := range defaults {
+		result[kind] = style
+	}
+	for name, styles := range spec {
+		kind := highlight.Kind(name)
+		valid := false
+		for _, k := range highlight.Kinds {
+			valid = valid || k == kind
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown token kind '%v'", name)
+		}
+
+		style, err := parseTextStyle(styles)
+		if err != nil {
+			return nil, fmt.Errorf("%v for token kind '%v'", err, name)
+		}
+		result[kind] = style
+	}
+	return result, nil
 }
 
-func loadStylesheet(path string) (style, error) {
+func loadHyphenation(style *hyphenationStyle, defaults *hyphen.Patterns, baseDir string) (*hyphen.Patterns, error) {
+	if style == nil {
+		return defaults, nil
+	}
+
+	patterns := defaults
+	if style.Patterns != "" {
+		contents, _, err := util.LoadFile(resolvePath(style.Patterns, baseDir))
+		if err != nil {
+			return nil, fmt.Errorf("error loading hyphenation patterns: %v", err)
+		}
+		if patterns, err = hyphen.Parse(string(contents)); err != nil {
+			return nil, fmt.Errorf("error parsing hyphenation patterns: %v", err)
+		}
+	}
+
+	if style.LeftMin != 0 || style.RightMin != 0 {
+		adjusted := *patterns
+		patterns = &adjusted
+		if style.LeftMin != 0 {
+			patterns.LeftMin = style.LeftMin
+		}
+		if style.RightMin != 0 {
+			patterns.RightMin = style.RightMin
+		}
+	}
+	return patterns, nil
+}
+
+// loadStylesheet loads the stylesheet at the given path for a device with the given DPI.
+func loadStylesheet(path string, dpi float64) (markdown.Style, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return style{}, err
+		return markdown.Style{}, err
 	}
 	defer f.Close()
 
 	var sheet styleSheet
 	if err = json.NewDecoder(f).Decode(&sheet); err != nil {
-		return style{}, err
+		return markdown.Style{}, err
 	}
 
-	proportionalFamily, err := loadFontFamily(sheet.ProportionalFamily, defaultStyle.proportionalFamily)
+	defaultStyle := defaultStyle(dpi)
+
+	// Fonts named by relative paths are found relative to the stylesheet.
+	baseDir := filepath.Dir(path)
+
+	fontCache := sheet.FontCache
+	if fontCache == "" {
+		fontCache = defaultFontCache()
+	}
+
+	proportionalFamily, err := loadFontFamily(sheet.ProportionalFamily, defaultStyle.ProportionalFamily, dpi, baseDir, fontCache)
 	if err != nil {
-		return style{}, err
+		return markdown.Style{}, err
 	}
 
-	monospaceFamily, err := loadFontFamily(sheet.MonospaceFamily, defaultStyle.monospaceFamily)
+	monospaceFamily, err := loadFontFamily(sheet.MonospaceFamily, defaultStyle.MonospaceFamily, dpi, baseDir, fontCache)
 	if err != nil {
-		return style{}, err
+		return markdown.Style{}, err
 	}
 
-	headingStyles := defaultStyle.headingStyles
+	fallbackFamilies := make([]*font.Family, len(sheet.FallbackFamilies))
+	for i, f := range sheet.FallbackFamilies {
+		if f == nil {
+			return markdown.Style{}, fmt.Errorf("fallback family %v: font family must not be null", i)
+		}
+		if fallbackFamilies[i], err = loadFontFamily(f, nil, dpi, baseDir, fontCache); err != nil {
+			return markdown.Style{}, fmt.Errorf("fallback family %v: %v", i, err)
+		}
+	}
+
+	headingStyles := defaultStyle.HeadingStyles
 	if len(sheet.HeadingStyles) > 0 {
 		headingStyles = make([]markdown.BlockStyle, len(sheet.HeadingStyles))
 		for i, s := range sheet.HeadingStyles {
-			headingStyles[i] = loadBlockStyle(s)
+			if headingStyles[i], err = loadBlockStyle(s); err != nil {
+				return markdown.Style{}, fmt.Errorf("heading style %v: %v", i, err)
+			}
 		}
 	}
 
-	paragraphStyle := defaultStyle.paragraphStyle
+	paragraphStyle := defaultStyle.ParagraphStyle
 	if sheet.ParagraphStyle != nil {
-		paragraphStyle = loadBlockStyle(*sheet.ParagraphStyle)
+		if paragraphStyle, err = loadBlockStyle(*sheet.ParagraphStyle); err != nil {
+			return markdown.Style{}, fmt.Errorf("paragraph style: %v", err)
+		}
+	}
+
+	linkShortener, err := loadShortener(sheet.Links, defaultStyle.Shortener, baseDir)
+	if err != nil {
+		return markdown.Style{}, err
+	}
+
+	linkCode := defaultStyle.LinkCode
+	if sheet.Links != nil && sheet.Links.Code != "" {
+		if linkCode, err = markdown.ParseLinkCode(sheet.Links.Code); err != nil {
+			return markdown.Style{}, err
+		}
+	}
+
+	highlightStyle, err := loadHighlight(sheet.Highlight, defaultStyle.Highlight)
+	if err != nil {
+		return markdown.Style{}, err
+	}
+
+	codeOverflow, minCodePointSize := defaultStyle.CodeOverflow, defaultStyle.MinCodePointSize
+	if sheet.CodeBlock != nil {
+		switch overflow := markdown.CodeOverflow(sheet.CodeBlock.Overflow); overflow {
+		case "":
+		case markdown.CodeOverflowWrap, markdown.CodeOverflowShrink, markdown.CodeOverflowRotate, markdown.CodeOverflowClip:
+			codeOverflow = overflow
+		default:
+			return markdown.Style{}, fmt.Errorf("unknown code block overflow policy '%v'", overflow)
+		}
+		if sheet.CodeBlock.MinPointSize != 0 {
+			minCodePointSize = sheet.CodeBlock.MinPointSize
+		}
+	}
+
+	hyphenation, err := loadHyphenation(sheet.Hyphenation, defaultStyle.Hyphenation, baseDir)
+	if err != nil {
+		return markdown.Style{}, err
+	}
+
+	codeBlockStyle := paragraphStyle
+	if sheet.CodeBlock != nil && sheet.CodeBlock.blockStyle != (blockStyle{}) {
+		if codeBlockStyle, err = loadBlockStyle(sheet.CodeBlock.blockStyle); err != nil {
+			return markdown.Style{}, fmt.Errorf("code block style: %v", err)
+		}
+	}
+
+	var inlineCode markdown.InlineCodeStyle
+	if sheet.InlineCode != nil {
+		inlineCode.Scale = sheet.InlineCode.Scale
+		if inlineCode.Style, err = parseTextStyle(sheet.InlineCode.Style); err != nil {
+			return markdown.Style{}, fmt.Errorf("inline code style: %v", err)
+		}
+	}
+
+	blockquote := defaultStyle.Blockquote
+	if sheet.Blockquote != nil {
+		if sheet.Blockquote.Indent != nil {
+			blockquote.Indent = sheet.Blockquote.Indent
+		}
+		if sheet.Blockquote.RuleThickness != nil {
+			blockquote.RuleThickness = *sheet.Blockquote.RuleThickness
+		}
+		if sheet.Blockquote.RuleStyle != "" {
+			if blockquote.RuleStyle, err = parseRuleStyle(sheet.Blockquote.RuleStyle); err != nil {
+				return markdown.Style{}, fmt.Errorf("blockquote style: %v", err)
+			}
+		}
+	}
+
+	list := defaultStyle.List
+	if sheet.List != nil {
+		if sheet.List.Indent != 0 {
+			list.Indent = sheet.List.Indent
+		}
+		if sheet.List.MarkerGap != 0 {
+			list.MarkerGap = sheet.List.MarkerGap
+		}
+		if len(sheet.List.Bullets) != 0 {
+			list.Bullets = sheet.List.Bullets
+		}
+		if len(sheet.List.Ordered) != 0 {
+			list.OrderedFormats = sheet.List.Ordered
+		}
+	}
+
+	var thematicBreak markdown.ThematicBreakStyle
+	if sheet.ThematicBreak != nil {
+		thematicBreak.Thickness, thematicBreak.Margin = sheet.ThematicBreak.Thickness, sheet.ThematicBreak.Margin
+		if thematicBreak.Style, err = parseRuleStyle(sheet.ThematicBreak.Style); err != nil {
+			return markdown.Style{}, fmt.Errorf("thematic break style: %v", err)
+		}
+	}
+
+	var linkCodeHeight float64
+	var linkCodeModuleSize int
+	if sheet.Links != nil {
+		linkCodeHeight, linkCodeModuleSize = sheet.Links.Height, sheet.Links.ModuleSize
 	}
 
-	return style{
-		proportionalFamily: proportionalFamily,
-		monospaceFamily:    monospaceFamily,
-		headingStyles:      headingStyles,
-		paragraphStyle:     paragraphStyle,
+	return markdown.Style{
+		ProportionalFamily: proportionalFamily,
+		MonospaceFamily:    monospaceFamily,
+		FallbackFamilies:   fallbackFamilies,
+		HeadingStyles:      headingStyles,
+		ParagraphStyle:     paragraphStyle,
+		Shortener:          linkShortener,
+		LinkCode:           linkCode,
+		PrintLinkURLs:      sheet.Links != nil && sheet.Links.PrintURL,
+		Highlight:          highlightStyle,
+		CodeOverflow:       codeOverflow,
+		MinCodePointSize:   minCodePointSize,
+		Hyphenation:        hyphenation,
+		CodeBlockStyle:     codeBlockStyle,
+		InlineCode:         inlineCode,
+		Blockquote:         blockquote,
+		List:               list,
+		ThematicBreak:      thematicBreak,
+		LinkCodeHeight:     linkCodeHeight,
+		LinkCodeModuleSize: linkCodeModuleSize,
 	}, nil
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/pgavlin/lilprinty/internal/markdown"
+	"github.com/pgavlin/lilprinty/internal/printer"
+)
+
+func TestLoadTypefacePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stylesheet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = os.Mkdir(filepath.Join(dir, "fonts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "fonts", "Go-Regular.ttf")
+	if err = ioutil.WriteFile(path, goregular.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relative paths are resolved against the base directory. Absolute paths are not.
+	for _, location := range []string{filepath.Join("fonts", "Go-Regular.ttf"), path} {
+		if _, err := loadTypeface(location, dir, ""); err != nil {
+			t.Errorf("loading '%v': %v", location, err)
+		}
+	}
+
+	// Locations with a font file extension are paths even if they contain no separators, so a missing file is
+	// reported as such rather than as a missing installed font.
+	if _, err := loadTypeface("Go-Regular.ttf", dir, ""); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
+
+func TestLoadStylesheetStorePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stylesheet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "style.json")
+	sheet := `{"links": {"shortener": "local", "baseURL": "http://printer.local/l/", "store": "links.txt"}}`
+	if err = ioutil.WriteFile(path, []byte(sheet), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The relative store path is resolved against the stylesheet's directory rather than the working directory.
+	style, err := loadStylesheet(path, printer.Profile58mm.DPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = style.Shortener.Shorten("https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "links.txt")); err != nil {
+		t.Fatalf("expected the store next to the stylesheet: %v", err)
+	}
+}
+
+func TestLoadStylesheetPatternsPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stylesheet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = os.Mkdir(filepath.Join(dir, "hyphenation"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	patterns := filepath.Join(dir, "hyphenation", "patterns.tex")
+	if err = ioutil.WriteFile(patterns, []byte(`\hyphenation{ bab-ba-bab }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "style.json")
+	sheet := `{"hyphenation": {"patterns": "hyphenation/patterns.tex", "leftMin": 1, "rightMin": 1}}`
+	if err = ioutil.WriteFile(path, []byte(sheet), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The relative patterns path is resolved against the stylesheet's directory rather than the working directory.
+	style, err := loadStylesheet(path, printer.Profile58mm.DPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if breaks := style.Hyphenation.Hyphenate("babbabab"); !reflect.DeepEqual(breaks, []int{3, 5}) {
+		t.Fatalf("expected breaks [3 5] from the loaded exceptions, got %v", breaks)
+	}
+}
+
+func TestLoadStylesheetBlockquote(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stylesheet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	indent := func(points float64) *float64 { return &points }
+
+	// Fields that a blockquote section omits keep their default values.
+	cases := []struct {
+		sheet    string
+		expected markdown.BlockquoteStyle
+	}{
+		{sheet: `{"blockquote": {}}`, expected: markdown.DefaultBlockquoteStyle},
+		{sheet: `{"blockquote": {"indent": 8}}`,
+			expected: markdown.BlockquoteStyle{Indent: indent(8), RuleStyle: markdown.RuleSolid}},
+		{sheet: `{"blockquote": {"indent": 0}}`,
+			expected: markdown.BlockquoteStyle{Indent: indent(0), RuleStyle: markdown.RuleSolid}},
+		{sheet: `{"blockquote": {"ruleThickness": 1.5}}`,
+			expected: markdown.BlockquoteStyle{RuleThickness: 1.5, RuleStyle: markdown.RuleSolid}},
+		{sheet: `{"blockquote": {"ruleStyle": "dashed"}}`,
+			expected: markdown.BlockquoteStyle{RuleStyle: markdown.RuleDashed}},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, "style.json")
+		if err = ioutil.WriteFile(path, []byte(c.sheet), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		style, err := loadStylesheet(path, printer.Profile58mm.DPI)
+		if err != nil {
+			t.Fatalf("%v: %v", c.sheet, err)
+		}
+		if !reflect.DeepEqual(style.Blockquote, c.expected) {
+			t.Errorf("%v: expected %+v, got %+v", c.sheet, c.expected, style.Blockquote)
+		}
+	}
+}